@@ -0,0 +1,109 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to project configuration files.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	yaml "github.com/advance512/yaml"
+)
+
+// The name of a project configuration file, looked up by tools in the root
+// directory of a project
+const ConfigFileName = "raml.yaml"
+
+// A Config holds the configuration of a project using RAML, as read from
+// its raml.yaml file, e.g.:
+//
+//	specs: [ api/api.raml ]
+//	includeRoot: api
+//	maxErrors: 20
+//	warningsAsErrors: true
+//
+// Sections meant for other tools are ignored, so they may share the file.
+type Config struct {
+
+	// The RAML files of the project. Relative paths are relative to the
+	// directory of the configuration file.
+	Specs []string `yaml:"specs"`
+
+	// Only allow including files below this directory. Relative to the
+	// directory of the configuration file when not absolute.
+	IncludeRoot string `yaml:"includeRoot"`
+
+	// The parser options of the same names
+	DisableIncludes     bool `yaml:"disableIncludes"`
+	SkipParameterChecks bool `yaml:"skipParameterChecks"`
+	MaxErrors           int  `yaml:"maxErrors"`
+	WarningsAsErrors    bool `yaml:"warningsAsErrors"`
+}
+
+// LoadConfig reads a project configuration file. Relative paths in it are
+// made relative to the current directory, like the path of the file itself.
+func LoadConfig(path string) (*Config, error) {
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read config file %s (Error: %s)",
+			path, err.Error())
+	}
+
+	config := new(Config)
+	if err := yaml.Unmarshal(contents, config); err != nil {
+		return nil, fmt.Errorf("Could not parse config file %s (Error: %s)",
+			path, err.Error())
+	}
+
+	directory := filepath.Dir(path)
+	for i, spec := range config.Specs {
+		if !filepath.IsAbs(spec) {
+			config.Specs[i] = filepath.Join(directory, spec)
+		}
+	}
+
+	if config.IncludeRoot != "" && !filepath.IsAbs(config.IncludeRoot) {
+		config.IncludeRoot = filepath.Join(directory, config.IncludeRoot)
+	}
+
+	return config, nil
+}
+
+// Returns the options for parsing the project's RAML files
+func (config *Config) ParserOptions() *ParserOptions {
+	return &ParserOptions{
+		DisableIncludes:     config.DisableIncludes,
+		SkipParameterChecks: config.SkipParameterChecks,
+		IncludeRoot:         config.IncludeRoot,
+		MaxErrors:           config.MaxErrors,
+		WarningsAsErrors:    config.WarningsAsErrors,
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to checking that the examples of
+// bodies agree with their schemas.
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Checks the JSON example of every body against its JSON schema, reporting
+// the fields of the example the schema doesn't declare and the fields the
+// schema requires which the example lacks. These usually mean only one of
+// the two was updated. Schemas may be inline or declared at the root.
+func checkExampleSchemas(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityWarning)
+
+	check := func(nodePath, schema, example string) {
+
+		if schema == "" || example == "" {
+			return
+		}
+		if !isInlineSchema(schema) {
+			declared, ok := api.GetSchema(strings.TrimSpace(schema))
+			if !ok {
+				return
+			}
+			schema = declared
+		}
+
+		for _, mismatch := range exampleMismatches(schema, example) {
+			report("example-schema-mismatch", nodePath+"/example", "%s",
+				mismatch)
+		}
+	}
+
+	api.walkBodies(func(path, method string, code HTTPCode, nodePath string,
+		bodies *Bodies) {
+
+		check(nodePath, bodies.DefaultSchema, bodies.DefaultExample)
+
+		var mediaTypes []string
+		for mediaType := range bodies.ForMIMEType {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+		sort.Strings(mediaTypes)
+
+		for _, mediaType := range mediaTypes {
+			body := bodies.ForMIMEType[mediaType]
+			check(nodePath+"/"+mediaType, body.Schema, body.Example)
+		}
+	})
+
+	return problems
+}
+
+// Returns a description of each field of a JSON example its JSON schema
+// doesn't declare, or that the schema requires and the example lacks.
+// Returns nothing when either isn't JSON.
+func exampleMismatches(schema, example string) []string {
+
+	var decodedSchema, decodedExample interface{}
+	if json.Unmarshal([]byte(schema), &decodedSchema) != nil ||
+		json.Unmarshal([]byte(example), &decodedExample) != nil {
+		return nil
+	}
+
+	var mismatches []string
+	collectExampleMismatches(decodedSchema, decodedExample, "", &mismatches)
+	return mismatches
+}
+
+// Adds the mismatches between a decoded example and the decoded schema it
+// should follow, going down into the properties and items both define.
+// Fields are referred to by their JSON pointers in the example.
+func collectExampleMismatches(schema, example interface{}, pointer string,
+	mismatches *[]string) {
+
+	schemaObject, ok := schema.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	switch example := example.(type) {
+	case map[string]interface{}:
+
+		properties, ok := schemaObject["properties"].(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		// Required properties are listed by the object in draft 4, and
+		// marked by themselves in draft 3
+		required := make(map[string]bool)
+		if names, ok := schemaObject["required"].([]interface{}); ok {
+			for _, name := range names {
+				if name, ok := name.(string); ok {
+					required[name] = true
+				}
+			}
+		}
+		for name, property := range properties {
+			if property, ok := property.(map[string]interface{}); ok &&
+				property["required"] == true {
+				required[name] = true
+			}
+		}
+
+		// Undeclared fields are fine when the schema explicitly allows
+		// them
+		additional, ok := schemaObject["additionalProperties"]
+		allowsAdditional := ok && additional != false
+
+		var names []string
+		for name := range properties {
+			names = append(names, name)
+		}
+		for name := range example {
+			if _, ok := properties[name]; !ok && !allowsAdditional {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+
+			value, inExample := example[name]
+			property, inSchema := properties[name]
+
+			switch {
+			case !inSchema:
+				*mismatches = append(*mismatches, fmt.Sprintf(
+					"%s/%s is in the example but not declared in the "+
+						"schema", pointer, name))
+			case !inExample && required[name]:
+				*mismatches = append(*mismatches, fmt.Sprintf(
+					"%s/%s is required by the schema but missing from the "+
+						"example", pointer, name))
+			case inExample:
+				collectExampleMismatches(property, value, pointer+"/"+name,
+					mismatches)
+			}
+		}
+
+	case []interface{}:
+		for i, element := range example {
+			collectExampleMismatches(schemaObject["items"], element,
+				fmt.Sprintf("%s/%d", pointer, i), mismatches)
+		}
+	}
+}
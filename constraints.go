@@ -0,0 +1,221 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to checking that the constraints of
+// named parameters and schemas can be satisfied.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The constraints a value must satisfy, as set by a named parameter or a
+// JSON schema. Nil fields are not constrained.
+type constraints struct {
+	minimum, maximum     *big.Rat
+	minLength, maxLength *big.Rat
+	pattern              *string
+
+	// Nil when there is no enum, as opposed to an empty enum
+	enum []interface{}
+}
+
+// Returns a description of each way the constraints contradict each other,
+// so that no value can satisfy them
+func (c constraints) conflicts() []string {
+
+	var conflicts []string
+
+	if c.minimum != nil && c.maximum != nil && c.minimum.Cmp(c.maximum) > 0 {
+		conflicts = append(conflicts, fmt.Sprintf("minimum %s greater than "+
+			"maximum %s", c.minimum.RatString(), c.maximum.RatString()))
+	}
+
+	if c.minLength != nil && c.maxLength != nil &&
+		c.minLength.Cmp(c.maxLength) > 0 {
+		conflicts = append(conflicts, fmt.Sprintf("minLength %s greater "+
+			"than maxLength %s", c.minLength.RatString(),
+			c.maxLength.RatString()))
+	}
+
+	if c.enum != nil && len(c.enum) == 0 {
+		conflicts = append(conflicts, "enum has no values")
+	}
+
+	// Patterns Go can't compile are left alone, as they may well be valid
+	// ECMA 262 regular expressions
+	if c.pattern != nil && len(c.enum) > 0 {
+		if pattern, err := regexp.Compile(*c.pattern); err == nil {
+			for _, value := range c.enum {
+				if !pattern.MatchString(fmt.Sprint(value)) {
+					conflicts = append(conflicts, fmt.Sprintf("enum value "+
+						"%v doesn't match pattern %s", value, *c.pattern))
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// Returns the constraints set by a named parameter
+func parameterConstraints(parameter NamedParameter) constraints {
+
+	var c constraints
+
+	if parameter.Minimum != nil {
+		c.minimum = parameter.Minimum.Rat()
+	}
+	if parameter.Maximum != nil {
+		c.maximum = parameter.Maximum.Rat()
+	}
+	if parameter.MinLength != nil {
+		c.minLength = big.NewRat(int64(*parameter.MinLength), 1)
+	}
+	if parameter.MaxLength != nil {
+		c.maxLength = big.NewRat(int64(*parameter.MaxLength), 1)
+	}
+	c.pattern = parameter.Pattern
+
+	if parameter.Enum != nil {
+		c.enum = make([]interface{}, len(parameter.Enum))
+		for i, value := range parameter.Enum {
+			c.enum[i] = value
+		}
+	}
+
+	return c
+}
+
+// Returns a description of each contradiction in the constraints of a JSON
+// schema and the subschemas it contains, each prefixed with the JSON pointer
+// to the subschema, e.g. /properties/name. Returns nothing for schemas that
+// aren't JSON.
+func jsonSchemaConflicts(schema string) []string {
+
+	decoder := json.NewDecoder(strings.NewReader(schema))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil
+	}
+
+	var conflicts []string
+	collectSchemaConflicts(decoded, "", &conflicts)
+	return conflicts
+}
+
+// Adds the contradictions in the constraints of every object of a decoded
+// JSON schema
+func collectSchemaConflicts(value interface{}, pointer string,
+	conflicts *[]string) {
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+
+		number := func(key string) *big.Rat {
+			if literal, ok := value[key].(json.Number); ok {
+				if rat, ok := new(big.Rat).SetString(string(literal)); ok {
+					return rat
+				}
+			}
+			return nil
+		}
+
+		c := constraints{
+			minimum:   number("minimum"),
+			maximum:   number("maximum"),
+			minLength: number("minLength"),
+			maxLength: number("maxLength"),
+		}
+		if pattern, ok := value["pattern"].(string); ok {
+			c.pattern = &pattern
+		}
+		if enum, ok := value["enum"].([]interface{}); ok {
+			c.enum = enum
+		}
+
+		for _, conflict := range c.conflicts() {
+			if pointer != "" {
+				conflict = pointer + ": " + conflict
+			}
+			*conflicts = append(*conflicts, conflict)
+		}
+
+		var keys []string
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		// Enum values and examples aren't subschemas
+		for _, key := range keys {
+			if key != "enum" && key != "example" && key != "default" {
+				collectSchemaConflicts(value[key], pointer+"/"+key, conflicts)
+			}
+		}
+
+	case []interface{}:
+		for i, element := range value {
+			collectSchemaConflicts(element, fmt.Sprintf("%s/%d", pointer, i),
+				conflicts)
+		}
+	}
+}
+
+// Checks that the constraints of every named parameter and JSON schema can
+// be satisfied, as values can't be valid otherwise
+func checkConstraints(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	api.walkNamedParameters(func(nodePath string, parameter NamedParameter,
+		form bool) {
+		for _, conflict := range parameterConstraints(parameter).conflicts() {
+			report("unsatisfiable-constraint", nodePath, "%s", conflict)
+		}
+	})
+
+	walkNodes(reflect.ValueOf(api), "", func(nodePath string, value reflect.Value) {
+		if value.Kind() == reflect.String && isSchemaNode(nodePath) {
+			for _, conflict := range jsonSchemaConflicts(value.String()) {
+				report("unsatisfiable-constraint", nodePath, "%s", conflict)
+			}
+		}
+	})
+
+	return problems
+}
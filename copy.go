@@ -0,0 +1,121 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to copying API definitions.
+
+import (
+	"reflect"
+)
+
+// Copy returns a deep copy of the API definition, sharing nothing with it,
+// so it can be modified while others keep reading the original.
+func (api *APIDefinition) Copy() *APIDefinition {
+
+	copied := deepCopy(reflect.ValueOf(api),
+		make(map[pointerCopy]reflect.Value)).Interface().(*APIDefinition)
+
+	// The index of the parsed document is never modified, so it is shared
+	copied.index = api.index
+
+	// Resolving the copy is reported like resolving the original
+	copied.listener = api.listener
+
+	return copied
+}
+
+// Identifies a pointer already copied
+type pointerCopy struct {
+	pointer   uintptr
+	valueType reflect.Type
+}
+
+// Returns a deep copy of a value. Unexported struct fields are left zero.
+// Pointers are copied once, keeping track of the copies made, so that
+// values referring to each other, e.g. resources and their parents, are
+// copied as such.
+func deepCopy(value reflect.Value, copies map[pointerCopy]reflect.Value) reflect.Value {
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return reflect.Zero(value.Type())
+		}
+		key := pointerCopy{value.Pointer(), value.Type()}
+		if copied, ok := copies[key]; ok {
+			return copied
+		}
+		copied := reflect.New(value.Type().Elem())
+		copies[key] = copied
+		copied.Elem().Set(deepCopy(value.Elem(), copies))
+		return copied
+
+	case reflect.Interface:
+		if value.IsNil() {
+			return reflect.Zero(value.Type())
+		}
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(deepCopy(value.Elem(), copies))
+		return copied
+
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath == "" {
+				copied.Field(i).Set(deepCopy(value.Field(i), copies))
+			}
+		}
+		return copied
+
+	case reflect.Slice:
+		if value.IsNil() {
+			return reflect.Zero(value.Type())
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopy(value.Index(i), copies))
+		}
+		return copied
+
+	case reflect.Map:
+		if value.IsNil() {
+			return reflect.Zero(value.Type())
+		}
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+		for _, key := range value.MapKeys() {
+			copied.SetMapIndex(deepCopy(key, copies),
+				deepCopy(value.MapIndex(key), copies))
+		}
+		return copied
+	}
+
+	// Anything else is a plain value, copied by assignment
+	copied := reflect.New(value.Type()).Elem()
+	copied.Set(value)
+	return copied
+}
@@ -0,0 +1,160 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to looking up the resource types,
+// traits, security schemes and schemas declared by API definitions.
+
+import (
+	"reflect"
+	"sort"
+)
+
+// The declarations of an API definition, flattened from the arrays of maps
+// of the RAML document into maps by name
+type declarations struct {
+	schemas         map[string]string
+	traits          map[string]*Trait
+	resourceTypes   map[string]*ResourceType
+	securitySchemes map[string]*SecurityScheme
+}
+
+// Flattens the declarations of an API definition. Returns the problems
+// found doing so, i.e. names declared more than once, which refer to node
+// paths. Only the first declaration of such names is kept.
+func newDeclarations(api *APIDefinition) (*declarations, []ParseError) {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	declared := &declarations{
+		schemas:         make(map[string]string),
+		traits:          make(map[string]*Trait),
+		resourceTypes:   make(map[string]*ResourceType),
+		securitySchemes: make(map[string]*SecurityScheme),
+	}
+
+	for _, schemas := range api.Schemas {
+		for _, name := range sortedKeys(schemas) {
+			if _, ok := declared.schemas[name]; ok {
+				report("duplicate-schema", "/schemas/"+name,
+					"schema %s is declared more than once", name)
+				continue
+			}
+			declared.schemas[name] = schemas[name]
+		}
+	}
+
+	for _, traits := range api.Traits {
+		for _, name := range sortedKeys(traits) {
+			if _, ok := declared.traits[name]; ok {
+				report("duplicate-trait", "/traits/"+name,
+					"trait %s is declared more than once", name)
+				continue
+			}
+			trait := traits[name]
+			declared.traits[name] = &trait
+		}
+	}
+
+	for _, resourceTypes := range api.ResourceTypes {
+		for _, name := range sortedKeys(resourceTypes) {
+			if _, ok := declared.resourceTypes[name]; ok {
+				report("duplicate-resource-type", "/resourceTypes/"+name,
+					"resource type %s is declared more than once", name)
+				continue
+			}
+			resourceType := resourceTypes[name]
+			declared.resourceTypes[name] = &resourceType
+		}
+	}
+
+	for _, securitySchemes := range api.SecuritySchemes {
+		for _, name := range sortedKeys(securitySchemes) {
+			if _, ok := declared.securitySchemes[name]; ok {
+				report("duplicate-security-scheme", "/securitySchemes/"+name,
+					"security scheme %s is declared more than once", name)
+				continue
+			}
+			securityScheme := securitySchemes[name]
+			declared.securitySchemes[name] = &securityScheme
+		}
+	}
+
+	return declared, problems
+}
+
+// Returns the names of a map of declarations by name, ordered
+func sortedKeys(declarations interface{}) []string {
+	var keys []string
+	for _, key := range reflect.ValueOf(declarations).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the flattened declarations of the API definition. They are made
+// anew on every call, so that they always reflect the arrays of maps they
+// are flattened from, even once these are changed.
+func (api *APIDefinition) declarations() *declarations {
+	declared, _ := newDeclarations(api)
+	return declared
+}
+
+// GetTrait returns the trait declared under the given name in the
+// root-level traits property. The trait shares its maps with the definition,
+// so it must not be modified.
+func (api *APIDefinition) GetTrait(name string) (*Trait, bool) {
+	trait, ok := api.declarations().traits[name]
+	return trait, ok
+}
+
+// GetResourceType returns the resource type declared under the given name
+// in the root-level resourceTypes property. The resource type shares its maps
+// with the definition, so it must not be modified.
+func (api *APIDefinition) GetResourceType(name string) (*ResourceType, bool) {
+	resourceType, ok := api.declarations().resourceTypes[name]
+	return resourceType, ok
+}
+
+// GetSecurityScheme returns the security scheme declared under the given
+// name in the root-level securitySchemes property, e.g. to resolve the
+// names given to securedBy. The security scheme shares its maps with
+// the definition, so it must not be modified.
+func (api *APIDefinition) GetSecurityScheme(name string) (*SecurityScheme, bool) {
+	securityScheme, ok := api.declarations().securitySchemes[name]
+	return securityScheme, ok
+}
+
+// GetSchema returns the schema declared under the given name in the
+// root-level schemas property
+func (api *APIDefinition) GetSchema(name string) (string, bool) {
+	schema, ok := api.declarations().schemas[name]
+	return schema, ok
+}
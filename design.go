@@ -0,0 +1,410 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to checking API definitions against
+// common REST design conventions. The conventions are opinions rather than
+// RAML rules, so breaking them is reported as warnings only.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Verbs that name actions rather than resources when starting a path
+// segment, e.g. /getUsers or /create-order
+var pathVerbs = map[string]bool{
+	"get": true, "set": true, "fetch": true, "retrieve": true,
+	"list": true, "create": true, "add": true, "insert": true,
+	"save": true, "update": true, "edit": true, "modify": true,
+	"delete": true, "remove": true,
+}
+
+// Checks that resources are named by plural nouns, that items are
+// addressed by URI parameters rather than query parameters, that creating
+// and deleting respond with the status codes meant for them, and that
+// parameter names are cased consistently.
+func checkDesign(api *APIDefinition) []ParseError {
+
+	var warnings []ParseError
+	report := problemReporter(&warnings, SeverityWarning)
+
+	// Nested resources share the segments of their parents, which are
+	// checked only once
+	checked := make(map[string]bool)
+
+	// The names of the URI and query parameters, by the nodes declaring them
+	parameters := make(map[string]string)
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		segments := strings.Split(uri, "/")
+		for i, segment := range segments {
+
+			prefix := strings.Join(segments[:i+1], "/")
+			if segment == "" || isTemplated(segment) {
+				continue
+			}
+
+			if word, _ := splitFirstWord(segment); !checked[prefix] &&
+				pathVerbs[strings.ToLower(word)] {
+				report("verb-in-path", nodePath, "resource %s uses the verb "+
+					"%s in its path; name resources by nouns and let the "+
+					"HTTP method tell what to do with them", prefix, word)
+			}
+			checked[prefix] = true
+
+			// Segments followed by a lone URI parameter are collections
+			if i+1 == len(segments) || !isURIParameter(segments[i+1]) {
+				continue
+			}
+			item := prefix + "/" + segments[i+1]
+			if plural := pluralize(segment); !checked[item] &&
+				plural != segment && !isParticiple(segment) {
+				plural = strings.TrimSuffix(prefix, segment) + plural
+				report("singular-collection", nodePath, "collection %s is "+
+					"named in the singular; name collections in the plural, "+
+					"e.g. %s, so that %s/%s reads as one of them", prefix,
+					plural, plural, segments[i+1])
+			}
+			checked[item] = true
+		}
+
+		for name := range resource.UriParameters {
+			parameters[nodePath+"/uriParameters/"+name] = name
+		}
+
+		// The name of the items of a collection, e.g. user for /users
+		item := ""
+		if last := segments[len(segments)-1]; !isTemplated(last) {
+			item = singularize(last)
+		}
+
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil {
+				continue
+			}
+			methodPath := nodePath + "/" + name
+
+			var queryNames []string
+			for queryName := range method.QueryParameters {
+				queryNames = append(queryNames, queryName)
+				parameters[methodPath+"/queryParameters/"+queryName] =
+					queryName
+			}
+			sort.Strings(queryNames)
+
+			for _, queryName := range queryNames {
+				if isItemID(queryName, item) {
+					report("id-in-query", methodPath+"/queryParameters/"+
+						queryName, "query parameter %s of method %s of "+
+						"resource %s identifies an item; address items by a "+
+						"URI parameter instead, e.g. %s/{%s}", queryName,
+						name, uri, uri, queryName)
+				}
+			}
+
+			checkDesignStatusCodes(report, uri, name, methodPath, method,
+				isCollection(resource))
+		}
+	})
+
+	checkParameterCasing(report, parameters)
+
+	return warnings
+}
+
+// Checks that the successful responses of GET methods declare how they may
+// be cached, through the Cache-Control header. The headers are looked up
+// once resource types and traits are applied, so they may be declared by
+// either. Each successful response lacking the header is reported.
+func checkCachePolicies(api *APIDefinition) []ParseError {
+
+	var warnings []ParseError
+	report := problemReporter(&warnings, SeverityWarning)
+
+	// Definitions that can't be resolved are reported by checkReferences,
+	// so check what they declare themselves instead
+	resolved, err := api.resolve(nil)
+	if err != nil {
+		resolved = api
+	}
+
+	resolved.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		if resource.Get == nil {
+			return
+		}
+
+		policy := resource.Get.CachePolicy()
+		for _, code := range sortedCodes(resource.Get.Responses, successful) {
+			if _, ok := policy.CacheControl[code]; !ok {
+				report("undeclared-cache-policy", fmt.Sprintf(
+					"%s/get/responses/%d", nodePath, code), "response %d "+
+					"of method get of resource %s declares no Cache-Control "+
+					"header; declare how clients and proxies may cache it",
+					code, uri)
+			}
+		}
+	})
+
+	return warnings
+}
+
+// Checks that GET methods serving ranges of their content declare it
+// consistently: the 206 Partial Content response tells which range it holds
+// through the Content-Range header, the 200 response advertises ranges
+// through the Accept-Ranges header, and advertising ranges comes with a 206
+// response. Headers are looked up once resource types and traits are applied.
+func checkRangeRequests(api *APIDefinition) []ParseError {
+
+	var warnings []ParseError
+	report := problemReporter(&warnings, SeverityWarning)
+
+	// Definitions that can't be resolved are reported by checkReferences,
+	// so check what they declare themselves instead
+	resolved, err := api.resolve(nil)
+	if err != nil {
+		resolved = api
+	}
+
+	resolved.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		if resource.Get == nil {
+			return
+		}
+		responses := resource.Get.Responses
+		responsesPath := nodePath + "/get/responses"
+
+		partial, ok := responses[206]
+		if !ok {
+			for _, code := range sortedCodes(responses, successful) {
+				response := responses[code]
+				if header, ok := response.Header("Accept-Ranges"); ok &&
+					declaredHeaderValue(header) != "none" {
+					report("range-requests", fmt.Sprintf("%s/%d",
+						responsesPath, code), "response %d of method get of "+
+						"resource %s accepts ranges, but the method declares "+
+						"no 206 response; declare the partial content it "+
+						"responds with", code, uri)
+				}
+			}
+			return
+		}
+
+		if _, ok := partial.Header("Content-Range"); !ok {
+			report("range-requests", responsesPath+"/206", "response 206 of "+
+				"method get of resource %s declares no Content-Range header; "+
+				"declare which range of the content it holds", uri)
+		}
+		if full, ok := responses[200]; ok {
+			if _, ok := full.Header("Accept-Ranges"); !ok {
+				report("range-requests", responsesPath+"/200", "response 200 "+
+					"of method get of resource %s declares no Accept-Ranges "+
+					"header, though the method responds 206; advertise the "+
+					"ranges clients may request", uri)
+			}
+		}
+	})
+
+	return warnings
+}
+
+// Checks that creating items, i.e. posting to collections, responds 201
+// Created, and deleting them 204 No Content. Methods declaring no successful
+// response at all aren't checked.
+func checkDesignStatusCodes(report func(rule string, nodePath string,
+	format string, args ...interface{}), uri, name, methodPath string,
+	method *Method, collection bool) {
+
+	codes := sortedCodes(method.Responses, successful)
+	declared := make(map[HTTPCode]bool)
+	withBody := false
+	for _, code := range codes {
+		declared[code] = true
+		withBody = withBody || hasBody(method.Responses[code].Bodies)
+	}
+
+	if len(codes) == 0 {
+		return
+	}
+
+	switch {
+	case name == "post" && collection && !declared[201] && !declared[202]:
+		report("create-status", methodPath+"/responses", "method post of "+
+			"resource %s responds %s, but not 201; creating an item should "+
+			"respond 201 Created, with the location of the new item",
+			uri, joinCodes(codes))
+
+	case name == "delete" && !withBody && !declared[204] && !declared[202]:
+		report("delete-status", methodPath+"/responses", "method delete of "+
+			"resource %s responds %s without a body; respond 204 No Content "+
+			"instead when there is nothing to return", uri, joinCodes(codes))
+	}
+}
+
+// Checks that the names of URI and query parameters are all cased alike,
+// e.g. in camelCase. Names that are a single lower case word fit any casing.
+func checkParameterCasing(report func(rule string, nodePath string,
+	format string, args ...interface{}), parameters map[string]string) {
+
+	var nodePaths []string
+	counts := make(map[string]int)
+	for nodePath, name := range parameters {
+		nodePaths = append(nodePaths, nodePath)
+		if casing := parameterCasing(name); casing != "" {
+			counts[casing]++
+		}
+	}
+	sort.Strings(nodePaths)
+
+	// Without a casing used more than any other, there is no telling which
+	// is the odd one out
+	common, tied := "", false
+	for casing, count := range counts {
+		switch {
+		case common == "" || count > counts[common]:
+			common, tied = casing, false
+		case count == counts[common]:
+			tied = true
+		}
+	}
+	if common == "" || tied {
+		return
+	}
+
+	for _, nodePath := range nodePaths {
+		name := parameters[nodePath]
+		if casing := parameterCasing(name); casing != "" && casing != common {
+			report("parameter-casing", nodePath, "parameter %s is in %s, "+
+				"while most parameters are in %s; case parameters alike so "+
+				"that clients don't have to guess", name, casing, common)
+		}
+	}
+}
+
+// Returns the casing of a parameter name, e.g. camelCase, or nothing when
+// it is a single lower case word
+func parameterCasing(name string) string {
+	switch {
+	case strings.Contains(name, "_"):
+		return "snake_case"
+	case strings.Contains(name, "-"):
+		return "kebab-case"
+	case name != "" && unicode.IsUpper([]rune(name)[0]):
+		return "PascalCase"
+	case strings.ToLower(name) != name:
+		return "camelCase"
+	}
+	return ""
+}
+
+// Reports whether a query parameter names the ID of an item of a
+// collection, e.g. id, or userId for the items of /users
+func isItemID(name, item string) bool {
+
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "").
+		Replace(name))
+
+	return normalized == "id" ||
+		item != "" && normalized == strings.ToLower(item)+"id"
+}
+
+// Reports whether a resource is a collection, i.e. has a nested resource
+// for each of its items, e.g. /{userId}
+func isCollection(resource *Resource) bool {
+	for uri := range resource.Nested {
+		if isURIParameter(strings.TrimPrefix(uri, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a word looks like a participle, e.g. following or
+// starred, which name relations rather than collections of items
+func isParticiple(word string) bool {
+	_, last := splitLastWord(word)
+	last = strings.ToLower(last)
+	return strings.HasSuffix(last, "ing") || strings.HasSuffix(last, "ed")
+}
+
+// Reports whether a path segment holds URI parameters, e.g. {userId}.json
+func isTemplated(segment string) bool {
+	return strings.Contains(segment, "{")
+}
+
+// Reports whether a path segment is a single URI parameter, e.g. {userId}
+func isURIParameter(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") &&
+		strings.Count(segment, "{") == 1
+}
+
+// Splits a value after its first word, which ends before the first
+// non-letter or upper case letter following a lower case one, e.g. "get"
+// and "Users" for getUsers
+func splitFirstWord(value string) (string, string) {
+
+	runes := []rune(value)
+
+	end := 0
+	for end < len(runes) && unicode.IsLetter(runes[end]) {
+		end++
+		if end < len(runes) && unicode.IsUpper(runes[end]) &&
+			unicode.IsLower(runes[end-1]) {
+			break
+		}
+	}
+
+	return string(runes[:end]), string(runes[end:])
+}
+
+// Reports whether bodies describe any content
+func hasBody(bodies Bodies) bool {
+	return len(bodies.ForMIMEType) > 0 || bodies.DefaultSchema != "" ||
+		bodies.DefaultExample != ""
+}
+
+// Returns status codes as a list, e.g. "200 and 202" or "200, 202 and 203"
+func joinCodes(codes []HTTPCode) string {
+
+	var names []string
+	for _, code := range codes {
+		names = append(names, fmt.Sprint(code))
+	}
+
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " +
+		names[len(names)-1]
+}
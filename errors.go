@@ -31,38 +31,128 @@ package raml
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
 	yaml "github.com/advance512/yaml"
 )
 
+// How serious a problem found in a RAML document is
+type Severity string
+
+const (
+	// The document is invalid
+	SeverityError Severity = "error"
+
+	// The document is valid, but likely not what its author meant
+	SeverityWarning Severity = "warning"
+)
+
+// A ParseError describes a single problem found in a RAML document
+type ParseError struct {
+
+	// The path of the included file the problem is in, or empty for the
+	// main document
+	File string `json:"file,omitempty"`
+
+	// The line and column of the problem within that file, starting at 1,
+	// or 0 when unknown. The column is that of the mapping key or tag on
+	// the line, as the YAML decoder only reports lines.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	// The path of the YAML node the problem is in, made of the keys of
+	// its enclosing mappings, e.g. /resources//users/get/responses/200.
+	// Sequence entries don't add to the path. Empty when unknown.
+	NodePath string `json:"nodePath,omitempty"`
+
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+
+	// Identifies the check that found the problem, e.g. undeclared-trait.
+	// Empty for problems found while decoding the document.
+	Rule string `json:"rule,omitempty"`
+}
+
+func (e ParseError) Error() string {
+	switch {
+	case e.Line > 0 && e.File != "":
+		return fmt.Sprintf("line %d of %s: %s", e.Line, e.File, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	case e.NodePath != "":
+		return fmt.Sprintf("%s: %s", e.NodePath, e.Message)
+	}
+	return e.Message
+}
+
 // A RamlError is returned by the ParseFile function when RAML or YAML problems
-// are encountered when parsing the RAML document.
+// are encountered when parsing the RAML document. When parsing with the
+// Lenient option, the partially parsed definition is returned along with it.
 type RamlError struct {
-	Errors []string
+	Errors []ParseError
 }
 
 func (e *RamlError) Error() string {
+
+	messages := make([]string, len(e.Errors))
+	for i, parseError := range e.Errors {
+		messages[i] = parseError.Error()
+	}
+
 	return fmt.Sprintf("Error parsing RAML:\n  %s\n",
-		strings.Join(e.Errors, "\n  "))
+		strings.Join(messages, "\n  "))
+}
+
+// Returns a RamlError holding the given problems. When maxErrors is
+// positive, at most that many are kept and the error notes that others were
+// dropped.
+func newRamlError(problems []ParseError, maxErrors int) *RamlError {
+
+	if maxErrors > 0 && len(problems) > maxErrors {
+		problems = append(problems[:maxErrors:maxErrors], ParseError{
+			Message:  "Too many errors, stopped reporting",
+			Severity: SeverityError,
+		})
+	}
+
+	return &RamlError{Errors: problems}
 }
 
-// Populate the RAML error value with converted YAML error strings (with
+// Populate the RAML error value with converted YAML errors (with
 // additional context)
 func populateRAMLError(ramlError *RamlError,
-	yamlErrors *yaml.TypeError) {
+	yamlErrors *yaml.TypeError, logger Logger) {
 
 	// Go over the errors
 	for _, currErr := range yamlErrors.Errors {
 
+		logger.Debugf("Converting YAML error: %s", currErr)
+
 		// Create the RAML errors
 		ramlError.Errors =
 			append(ramlError.Errors, convertYAMLError(currErr))
 	}
 }
 
-// Convert a YAML error string into RAML error string, with more context
-func convertYAMLError(yamlError string) string {
+// Matches the line number YAML error messages start with, e.g.
+// "yaml: line 12: ..."
+var yamlErrorLineRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Convert a YAML error string into a RAML error, with more context. The line
+// of the error refers to the document given to the YAML decoder.
+func convertYAMLError(yamlError string) ParseError {
+
+	parseError := ParseError{
+		Message:  fmt.Sprintf("YAML error, %s", yamlError),
+		Severity: SeverityError,
+	}
+
+	if match := yamlErrorLineRegexp.FindStringSubmatch(yamlError); match != nil {
+		parseError.Line, _ = strconv.Atoi(match[1])
+		parseError.Message = fmt.Sprintf("YAML error, %s", match[2])
+	}
 
 	if strings.Contains(yamlError, "cannot unmarshal") {
 
@@ -70,14 +160,10 @@ func convertYAMLError(yamlError string) string {
 
 		if len(yamlErrorParts) >= 7 {
 
-			fmt.Println(yamlError)
-
 			var ok bool
 			var source string
 			var target string
 			var targetName string
-			line := yamlErrorParts[1]
-			line = line[:len(line)-1]
 
 			// TODO: support more complex types:
 			// map[string]raml.NamedParameter -->
@@ -97,7 +183,6 @@ func convertYAMLError(yamlError string) string {
 			if source, ok = yamlTypeToName[yamlErrorParts[4]]; !ok {
 				source = yamlErrorParts[4]
 			}
-			fmt.Println("source: ", source)
 
 			if source == "string" {
 				source = fmt.Sprintf("string (got %s)", yamlErrorParts[5])
@@ -112,14 +197,12 @@ func convertYAMLError(yamlError string) string {
 
 			target, _ = ramlTypes[target]
 
-			return fmt.Sprintf("line %s: %s cannot be of "+
-				"type %s, must be %s", line, targetName, source, target)
-
+			parseError.Message = fmt.Sprintf("%s cannot be of "+
+				"type %s, must be %s", targetName, source, target)
 		}
 	}
 
-	// Otherwise
-	return fmt.Sprintf("YAML error, %s", yamlError)
+	return parseError
 }
 
 var yamlTypeToName map[string]string = map[string]string{
@@ -136,8 +219,9 @@ var yamlTypeToName map[string]string = map[string]string{
 }
 
 var ramlTypeNames map[string]string = map[string]string{
-	"string": "string value",
-	"int":    "numeric value",
+	"string":                    "string value",
+	"int":                       "numeric value",
+	"raml.Number":               "number",
 	"raml.NamedParameter":       "named parameter",
 	"raml.HTTPCode":             "HTTP code",
 	"raml.HTTPHeader":           "HTTP header",
@@ -158,8 +242,9 @@ var ramlTypeNames map[string]string = map[string]string{
 }
 
 var ramlTypes map[string]string = map[string]string{
-	"string": "string",
-	"int":    "integer",
+	"string":                    "string",
+	"int":                       "integer",
+	"raml.Number":               "number",
 	"raml.NamedParameter":       "mapping",
 	"raml.HTTPCode":             "integer",
 	"raml.HTTPHeader":           "string",
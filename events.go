@@ -0,0 +1,81 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to telling listeners what happens
+// while parsing and resolving API definitions.
+
+// The kinds of events a ParseListener receives
+type ParseEventKind string
+
+const (
+	// A file referenced via !include was read
+	EventFileIncluded ParseEventKind = "fileIncluded"
+
+	// A resource was decoded, including nested resources
+	EventResourceParsed ParseEventKind = "resourceParsed"
+
+	// A trait was applied to a method when resolving the definition
+	EventTraitApplied ParseEventKind = "traitApplied"
+
+	// A problem was found, whether an error or a warning
+	EventIssueFound ParseEventKind = "issueFound"
+)
+
+// Something that happened while parsing or resolving an API definition.
+// Only the fields relevant to its kind are set.
+type ParseEvent struct {
+	Kind ParseEventKind
+
+	// The path of the included file
+	File string
+
+	// The full URI template of the resource parsed or whose method the
+	// trait was applied to, e.g. /users/{userId}
+	ResourcePath string
+
+	// The name of the method and of the trait applied to it
+	Method string
+	Trait  string
+
+	// The problem found
+	Issue ParseError
+}
+
+// A ParseListener receives the events of parsing as they happen, e.g. to
+// show progress or to react to specific constructs without walking the
+// definition afterwards. It is called from the goroutine parsing or
+// resolving the definition.
+type ParseListener func(event ParseEvent)
+
+// Passes an event to the listener, if there is one
+func (listener ParseListener) notify(event ParseEvent) {
+	if listener != nil {
+		listener(event)
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to extracting examples from an API
+// definition.
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// An example exchange with a method: an example request and an example
+// response, with their media types. Either example may be empty when the
+// method only documents the other side of the exchange.
+type ExamplePair struct {
+
+	// The full URI template of the resource, e.g. /users/{userId}
+	ResourcePath string
+
+	// The HTTP method name, e.g. get
+	Method string
+
+	// The media type and example of the request body. The media type is
+	// the API's default media type when the body doesn't name one.
+	RequestMediaType string
+	RequestExample   string
+
+	// The status code, media type and example of the response body. The
+	// media type is the API's default media type when the body doesn't
+	// name one.
+	StatusCode        HTTPCode
+	ResponseMediaType string
+	ResponseExample   string
+}
+
+// A body example with its media type
+type mediaTypeExample struct {
+	mediaType string
+	example   string
+}
+
+// Returns every pairing of a request example with a response example of the
+// same method, across the whole API. Methods documenting only requests or
+// only responses yield pairs with an empty counterpart. Pairs are ordered
+// by resource path, method, status code and media types.
+func (api *APIDefinition) Examples() []ExamplePair {
+
+	var pairs []ExamplePair
+
+	api.walkResources(func(path string, resource *Resource) {
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil {
+				continue
+			}
+
+			requests := api.bodyExamples(method.Bodies)
+			if len(requests) == 0 {
+				requests = []mediaTypeExample{{}}
+			}
+
+			responded := false
+			for _, code := range sortedCodes(method.Responses, nil) {
+				response := method.Responses[code]
+				for _, responseExample := range api.bodyExamples(response.Bodies) {
+					for _, requestExample := range requests {
+						responded = true
+						pairs = append(pairs, ExamplePair{
+							ResourcePath:      path,
+							Method:            name,
+							RequestMediaType:  requestExample.mediaType,
+							RequestExample:    requestExample.example,
+							StatusCode:        code,
+							ResponseMediaType: responseExample.mediaType,
+							ResponseExample:   responseExample.example,
+						})
+					}
+				}
+			}
+
+			// Still report requests without any example response
+			if !responded {
+				for _, requestExample := range requests {
+					if requestExample.example != "" {
+						pairs = append(pairs, ExamplePair{
+							ResourcePath:     path,
+							Method:           name,
+							RequestMediaType: requestExample.mediaType,
+							RequestExample:   requestExample.example,
+						})
+					}
+				}
+			}
+		}
+	})
+
+	return pairs
+}
+
+// Returns the examples of a body, ordered by media type
+func (api *APIDefinition) bodyExamples(bodies Bodies) []mediaTypeExample {
+
+	var examples []mediaTypeExample
+
+	if bodies.DefaultExample != "" {
+		examples = append(examples,
+			mediaTypeExample{api.MediaType, bodies.DefaultExample})
+	}
+
+	var mediaTypes []string
+	for mediaType, body := range bodies.ForMIMEType {
+		if body.Example != "" {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	sort.Strings(mediaTypes)
+
+	for _, mediaType := range mediaTypes {
+		examples = append(examples, mediaTypeExample{mediaType,
+			bodies.ForMIMEType[mediaType].Example})
+	}
+
+	return examples
+}
+
+// Limits on the size of the body examples of an API definition, e.g. to keep
+// published documentation manageable
+type ExamplePolicy struct {
+
+	// Keep only the first elements of the arrays of JSON examples, up to
+	// this many. Zero means no limit.
+	MaxArrayElements int
+
+	// Remove examples still larger than this many bytes. Zero means no
+	// limit.
+	MaxSize int
+}
+
+// Describes an example changed by PruneExamples
+type PrunedExample struct {
+
+	// The path of the example's node, e.g.
+	// /resources//users/get/responses/200/body/application/json/example
+	NodePath string
+
+	// The sizes of the example in bytes, before and after pruning
+	OriginalSize int
+	Size         int
+
+	// Whether the example was removed altogether
+	Removed bool
+}
+
+// PruneExamples applies the policy to the request and response body
+// examples of the API definition, and returns what it changed, ordered by
+// node path. JSON examples whose arrays were shortened are encoded again,
+// with their object keys ordered. Returns a copy of the API definition with
+// the examples pruned; the definition itself is left as it is.
+func (api *APIDefinition) PruneExamples(
+	policy ExamplePolicy) (*APIDefinition, []PrunedExample) {
+
+	prunedAPI := api.Copy()
+	var pruned []PrunedExample
+
+	prune := func(nodePath string, example *string) {
+
+		if *example == "" {
+			return
+		}
+
+		original := len(*example)
+		*example = pruneArrays(*example, policy.MaxArrayElements)
+
+		removed := policy.MaxSize > 0 && len(*example) > policy.MaxSize
+		if removed {
+			*example = ""
+		}
+
+		if removed || len(*example) != original {
+			pruned = append(pruned, PrunedExample{NodePath: nodePath,
+				OriginalSize: original, Size: len(*example), Removed: removed})
+		}
+	}
+
+	prunedAPI.updateBodies(func(path, method string, code HTTPCode, nodePath string,
+		bodies *Bodies) {
+
+		prune(nodePath+"/example", &bodies.DefaultExample)
+
+		var mediaTypes []string
+		for mediaType := range bodies.ForMIMEType {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+		sort.Strings(mediaTypes)
+
+		for _, mediaType := range mediaTypes {
+			body := bodies.ForMIMEType[mediaType]
+			prune(nodePath+"/"+mediaType+"/example", &body.Example)
+			bodies.ForMIMEType[mediaType] = body
+		}
+	})
+
+	sort.SliceStable(pruned, func(i, j int) bool {
+		return pruned[i].NodePath < pruned[j].NodePath
+	})
+
+	return prunedAPI, pruned
+}
+
+// Keeps only the first elements of every array of a JSON example, up to
+// maxElements. Returns the example as is when it isn't JSON or has no
+// longer arrays.
+func pruneArrays(example string, maxElements int) string {
+
+	if maxElements <= 0 {
+		return example
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(example))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return example
+	}
+
+	// Examples with more after the JSON value, e.g. a second value or a
+	// stray closing bracket, aren't JSON, and would lose the rest when
+	// encoded again
+	if decoder.More() {
+		return example
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return example
+	}
+
+	decoded, trimmed := trimArrays(decoded, maxElements)
+	if !trimmed {
+		return example
+	}
+
+	// Keep <, > and & as they are, rather than escaped for HTML
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if strings.Contains(strings.TrimSpace(example), "\n") {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(decoded); err != nil {
+		return example
+	}
+
+	// The encoder ends the value with a line break, which only block
+	// scalars keep
+	pruned := strings.TrimSuffix(encoded.String(), "\n")
+	if strings.HasSuffix(example, "\n") {
+		pruned += "\n"
+	}
+
+	return pruned
+}
+
+// Shortens the arrays of a decoded JSON value to maxElements. Reports
+// whether any was shortened.
+func trimArrays(value interface{}, maxElements int) (interface{}, bool) {
+
+	trimmed := false
+
+	switch value := value.(type) {
+	case []interface{}:
+		if len(value) > maxElements {
+			value = value[:maxElements]
+			trimmed = true
+		}
+		for i, element := range value {
+			var elementTrimmed bool
+			value[i], elementTrimmed = trimArrays(element, maxElements)
+			trimmed = trimmed || elementTrimmed
+		}
+		return value, trimmed
+
+	case map[string]interface{}:
+		for key, element := range value {
+			var elementTrimmed bool
+			value[key], elementTrimmed = trimArrays(element, maxElements)
+			trimmed = trimmed || elementTrimmed
+		}
+	}
+
+	return value, trimmed
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to explaining how the resolved
+// definitions of methods come about.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// How a resource type or trait was applied when resolving a method
+type AppliedDefinition struct {
+
+	// The name of the resource type or trait
+	Name string
+
+	// Where the trait was applied: by the resource, or by the method
+	// itself. Empty for resource types.
+	AppliedBy string
+
+	// The values substituted for the parameters of the definition,
+	// including the reserved resourcePath, resourcePathName and methodName
+	Parameters DefinitionParameters
+}
+
+// An explanation of how Resolve computes the definition of a method
+type Explanation struct {
+
+	// The full URI template of the resource, e.g. /users/{userId}, and the
+	// method name, e.g. get
+	ResourcePath string
+	Method       string
+
+	// The resource type applied to the resource first, or nil if none is
+	ResourceType *AppliedDefinition
+
+	// The traits applied to the method next, in the order they are applied
+	Traits []AppliedDefinition
+
+	// The definition of the method resulting from all of the above
+	Resolved *Method
+}
+
+// Explain tells how the definition of a method is resolved: which resource
+// type is applied, then which traits in which order, and with which values
+// for their parameters, e.g. to debug large hierarchies of resource types
+// and traits. The resolved method is part of the explanation. Fails like
+// Resolve, or when the resource or the method isn't defined.
+func (api *APIDefinition) Explain(resourcePath, method string) (*Explanation, error) {
+
+	method = strings.ToLower(method)
+
+	var original *Resource
+	api.walkResources(func(path string, resource *Resource) {
+		if path == resourcePath {
+			original = resource
+		}
+	})
+	if original == nil {
+		return nil, fmt.Errorf("Resource %s is not defined", resourcePath)
+	}
+
+	resolved, err := api.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	var resource *Resource
+	resolved.walkResources(func(path string, walked *Resource) {
+		if path == resourcePath {
+			resource = walked
+		}
+	})
+
+	explanation := &Explanation{ResourcePath: resourcePath, Method: method,
+		Resolved: resource.method(method)}
+	if explanation.Resolved == nil {
+		return nil, fmt.Errorf("Resource %s has no method %s", resourcePath,
+			method)
+	}
+
+	if original.Type != nil {
+		explanation.ResourceType = &AppliedDefinition{
+			Name: original.Type.Name,
+			Parameters: withReservedParameters(original.Type.Parameters,
+				resourcePath, ""),
+		}
+	}
+
+	// The traits applied by the resource come first
+	byResource := 0
+	for _, choice := range resource.Is {
+		if choice.AppliesTo(method) {
+			byResource++
+		}
+	}
+
+	for i, choice := range resource.MethodTraits(method) {
+
+		appliedBy := "method"
+		if i < byResource {
+			appliedBy = "resource"
+		}
+
+		explanation.Traits = append(explanation.Traits, AppliedDefinition{
+			Name: choice.Name, AppliedBy: appliedBy,
+			Parameters: withReservedParameters(choice.Parameters,
+				resourcePath, method),
+		})
+	}
+
+	return explanation, nil
+}
+
+// Returns the explanation as text, one line per definition applied, e.g.
+//
+//	GET /users/{userId}
+//	  resource type member (resourcePath: /users/{userId}, ...)
+//	  trait paged, applied by the method (methodName: get, ...)
+func (explanation *Explanation) String() string {
+
+	lines := []string{strings.ToUpper(explanation.Method) + " " +
+		explanation.ResourcePath}
+
+	if applied := explanation.ResourceType; applied != nil {
+		lines = append(lines, fmt.Sprintf("  resource type %s (%s)",
+			applied.Name, formatParameters(applied.Parameters)))
+	}
+
+	for _, applied := range explanation.Traits {
+		lines = append(lines, fmt.Sprintf("  trait %s, applied by the %s (%s)",
+			applied.Name, applied.AppliedBy,
+			formatParameters(applied.Parameters)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Returns parameters as a list of names and values, ordered by name
+func formatParameters(parameters DefinitionParameters) string {
+
+	var names []string
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var formatted []string
+	for _, name := range names {
+		formatted = append(formatted, name+": "+parameters[name])
+	}
+
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,153 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to identifying issues across runs,
+// e.g. to only fail on issues not found before.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// Matches line numbers mentioned in messages, e.g. "first on line 12"
+var messageLineRegexp = regexp.MustCompile(`line \d+`)
+
+// Fingerprint identifies an issue across runs. It is made of the rule, the
+// file and node path the issue is in, and the message, so it doesn't change
+// when lines are added or removed elsewhere in the document. Line numbers
+// are only used for issues not located by a node path, and are left out of
+// messages. Issues repeated in the same node share a fingerprint.
+func (e ParseError) Fingerprint() string {
+
+	location := e.NodePath
+	if location == "" {
+		location = fmt.Sprintf("line %d", e.Line)
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{e.Rule, e.File, location,
+		messageLineRegexp.ReplaceAllString(e.Message, "line")} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// A Baseline holds the fingerprints of known issues, e.g. those of the last
+// run on the main branch, to tell new issues from them
+type Baseline struct {
+	Fingerprints []string `json:"fingerprints"`
+}
+
+// Returns a baseline of the issues of the report
+func (report *ValidationReport) Baseline() *Baseline {
+
+	known := make(map[string]bool)
+	baseline := &Baseline{Fingerprints: []string{}}
+	for _, issue := range report.Issues {
+		if fingerprint := issue.Fingerprint(); !known[fingerprint] {
+			known[fingerprint] = true
+			baseline.Fingerprints = append(baseline.Fingerprints, fingerprint)
+		}
+	}
+	sort.Strings(baseline.Fingerprints)
+
+	return baseline
+}
+
+// Returns the issues of the report not in the baseline. A nil baseline is
+// empty, so all issues are new.
+func (report *ValidationReport) NewIssues(baseline *Baseline) []ParseError {
+
+	known := baseline.fingerprints()
+	var issues []ParseError
+	for _, issue := range report.Issues {
+		if !known[issue.Fingerprint()] {
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues
+}
+
+// Returns the fingerprints of the baseline whose issues aren't in the
+// report anymore. A nil baseline is empty, so none are.
+func (report *ValidationReport) FixedIssues(baseline *Baseline) []string {
+
+	if baseline == nil {
+		return nil
+	}
+
+	found := report.Baseline().fingerprints()
+	var fixed []string
+	for _, fingerprint := range baseline.Fingerprints {
+		if !found[fingerprint] {
+			fixed = append(fixed, fingerprint)
+		}
+	}
+
+	return fixed
+}
+
+// Returns the fingerprints of the baseline as a set, empty for a nil
+// baseline
+func (baseline *Baseline) fingerprints() map[string]bool {
+	if baseline == nil {
+		return map[string]bool{}
+	}
+	known := make(map[string]bool, len(baseline.Fingerprints))
+	for _, fingerprint := range baseline.Fingerprints {
+		known[fingerprint] = true
+	}
+	return known
+}
+
+// Writes the baseline as JSON, to be read back by LoadBaseline
+func (baseline *Baseline) Save(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(baseline); err != nil {
+		return fmt.Errorf("Error writing baseline (Error: %s)", err.Error())
+	}
+	return nil
+}
+
+// Reads a baseline written by Baseline.Save
+func LoadBaseline(r io.Reader) (*Baseline, error) {
+	baseline := new(Baseline)
+	if err := json.NewDecoder(r).Decode(baseline); err != nil {
+		return nil, fmt.Errorf("Error reading baseline (Error: %s)",
+			err.Error())
+	}
+	return baseline, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to grouping the resources of API
+// definitions, e.g. to navigate the documentation of large APIs.
+
+import (
+	"sort"
+	"strings"
+)
+
+// What the resources of a ResourceGroup have in common
+type GroupKind string
+
+const (
+	// Resources under the same top-level path segment, e.g. /users
+	GroupByPathPrefix GroupKind = "pathPrefix"
+
+	// Resources of the same resource type
+	GroupByResourceType GroupKind = "resourceType"
+
+	// Resources with bodies referring to the same declared schema
+	GroupBySchema GroupKind = "schema"
+)
+
+// A proposed group of related resources
+type ResourceGroup struct {
+	Kind GroupKind
+
+	// The path prefix, resource type or schema name the resources share
+	Name string
+
+	// The full URI templates of the resources, e.g. /users/{userId}, in
+	// order
+	Resources []string
+}
+
+// ResourceGroups proposes groupings of the resources of the API: by their
+// top-level path segment, by resource type and by the declared schemas their
+// bodies refer to. Only groups of two resources or more are proposed, as
+// the others don't help navigating. Groups are ordered by kind and name.
+//
+// RAML 0.8 has no tags or annotations to record the groups in, so it's up
+// to the caller to use them, e.g. to organize generated documentation.
+func (api *APIDefinition) ResourceGroups() []ResourceGroup {
+
+	members := make(map[GroupKind]map[string]map[string]bool)
+	add := func(kind GroupKind, name, path string) {
+		if members[kind] == nil {
+			members[kind] = make(map[string]map[string]bool)
+		}
+		if members[kind][name] == nil {
+			members[kind][name] = make(map[string]bool)
+		}
+		members[kind][name][path] = true
+	}
+
+	api.walkResources(func(path string, resource *Resource) {
+
+		segments := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+		add(GroupByPathPrefix, "/"+segments[0], path)
+
+		if resource.Type != nil {
+			add(GroupByResourceType, resource.Type.Name, path)
+		}
+	})
+
+	api.walkBodies(func(path, method string, code HTTPCode, nodePath string,
+		bodies *Bodies) {
+
+		schemas := []string{bodies.DefaultSchema}
+		for _, body := range bodies.ForMIMEType {
+			schemas = append(schemas, body.Schema)
+		}
+
+		for _, schema := range schemas {
+			name := strings.TrimSpace(schema)
+			if name == "" || isInlineSchema(name) {
+				continue
+			}
+			if _, ok := api.GetSchema(name); ok {
+				add(GroupBySchema, name, path)
+			}
+		}
+	})
+
+	var groups []ResourceGroup
+	for _, kind := range []GroupKind{GroupByPathPrefix, GroupByResourceType,
+		GroupBySchema} {
+
+		var names []string
+		for name := range members[kind] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+
+			if len(members[kind][name]) < 2 {
+				continue
+			}
+
+			var paths []string
+			for path := range members[kind][name] {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+
+			groups = append(groups, ResourceGroup{kind, name, paths})
+		}
+	}
+
+	return groups
+}
@@ -0,0 +1,216 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to singularizing and pluralizing
+// English words, for the !singularize and !pluralize parameter functions.
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// A rule inflecting the words matching a pattern
+type inflection struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// Returns inflection rules from pairs of patterns and replacements. The
+// patterns are matched case insensitively.
+func inflections(rules ...string) []inflection {
+	var compiled []inflection
+	for i := 0; i < len(rules); i += 2 {
+		compiled = append(compiled,
+			inflection{regexp.MustCompile("(?i)" + rules[i]), rules[i+1]})
+	}
+	return compiled
+}
+
+// The rules turning singular words into plural ones, by order of precedence
+var pluralInflections = inflections(
+	`(quiz)$`, "${1}zes",
+	`^(oxen)$`, "${1}",
+	`^(ox)$`, "${1}en",
+	`(matr|vert|ind)(?:ix|ex)$`, "${1}ices",
+	`(x|ch|ss|sh|zz)$`, "${1}es",
+	`([^aeiouy]|qu)y$`, "${1}ies",
+	`(hive)$`, "${1}s",
+	`([^f])fe$`, "${1}ves",
+	`([lr])f$`, "${1}ves",
+	`sis$`, "ses",
+	`([ti])a$`, "${1}a",
+	`([ti])um$`, "${1}a",
+	`(buffal|tomat|potat|her)o$`, "${1}oes",
+	`(bu)s$`, "${1}ses",
+	`(alias|status)$`, "${1}es",
+	`(octop|vir)i$`, "${1}i",
+	`(octop|vir)us$`, "${1}i",
+	`(ax|test)is$`, "${1}es",
+	`s$`, "s",
+	`$`, "s",
+)
+
+// The rules turning plural words into singular ones, by order of precedence
+var singularInflections = inflections(
+	`(database)s$`, "${1}",
+	`(quiz)zes$`, "${1}",
+	`(matr)ices$`, "${1}ix",
+	`(vert|ind)ices$`, "${1}ex",
+	`^(ox)en`, "${1}",
+	`(alias|status)(?:es)?$`, "${1}",
+	`(octop|vir)(?:us|i)$`, "${1}us",
+	`^(a)x[ie]s$`, "${1}xis",
+	`(cris|test)(?:is|es)$`, "${1}is",
+	`(shoe)s$`, "${1}",
+	`(o)es$`, "${1}",
+	`(bus)(?:es)?$`, "${1}",
+	`(x|ch|ss|sh|zz)es$`, "${1}",
+	`(m)ovies$`, "${1}ovie",
+	`(s)eries$`, "${1}eries",
+	`([^aeiouy]|qu)ies$`, "${1}y",
+	`([lr])ves$`, "${1}f",
+	`(tive)s$`, "${1}",
+	`(hive)s$`, "${1}",
+	`([^f])ves$`, "${1}fe",
+	`((a)naly|(b)a|(d)iagno|(p)arenthe|(p)rogno|(s)ynop|(t)he)(?:sis|ses)$`, "${1}sis",
+	`([ti])a$`, "${1}um",
+	`(n)ews$`, "${1}ews",
+	`(ss)$`, "${1}",
+	`s$`, "",
+)
+
+// Words whose plural doesn't follow the rules, by their singular
+var irregularPlurals = map[string]string{
+	"person": "people",
+	"man":    "men",
+	"woman":  "women",
+	"child":  "children",
+	"tooth":  "teeth",
+	"foot":   "feet",
+	"mouse":  "mice",
+	"goose":  "geese",
+	"move":   "moves",
+	"zombie": "zombies",
+}
+
+// Words whose singular and plural are the same
+var uncountableWords = map[string]bool{
+	"equipment":   true,
+	"information": true,
+	"rice":        true,
+	"money":       true,
+	"species":     true,
+	"series":      true,
+	"fish":        true,
+	"sheep":       true,
+	"deer":        true,
+	"news":        true,
+	"data":        true,
+	"metadata":    true,
+	"media":       true,
+	"feedback":    true,
+}
+
+// Returns the plural of an English word, e.g. users for user. Only the
+// last word of identifiers is changed, e.g. userAccounts for userAccount.
+func pluralize(value string) string {
+	return inflectLastWord(value, pluralInflections, irregularPlurals)
+}
+
+// Returns the singular of an English word, e.g. user for users. Only the
+// last word of identifiers is changed, e.g. userAccount for userAccounts.
+func singularize(value string) string {
+	singulars := make(map[string]string, len(irregularPlurals))
+	for singular, plural := range irregularPlurals {
+		singulars[plural] = singular
+	}
+	return inflectLastWord(value, singularInflections, singulars)
+}
+
+// Inflects the last word of a value with the first matching rule, unless it
+// is irregular or uncountable
+func inflectLastWord(value string, rules []inflection,
+	irregulars map[string]string) string {
+
+	prefix, word := splitLastWord(value)
+	lower := strings.ToLower(word)
+
+	if word == "" || uncountableWords[lower] {
+		return value
+	}
+
+	if irregular, ok := irregulars[lower]; ok {
+		return prefix + matchCase(word, irregular)
+	}
+
+	for _, rule := range rules {
+		if rule.pattern.MatchString(word) {
+			return prefix + matchCase(word,
+				rule.pattern.ReplaceAllString(word, rule.replacement))
+		}
+	}
+
+	return value
+}
+
+// Splits a value before its last word, which starts after the last
+// non-letter or at the last upper case letter following a lower case one,
+// e.g. "user" and "Accounts" for userAccounts
+func splitLastWord(value string) (string, string) {
+
+	runes := []rune(value)
+
+	start := len(runes)
+	for start > 0 && unicode.IsLetter(runes[start-1]) {
+		start--
+		if start > 0 && unicode.IsUpper(runes[start]) &&
+			unicode.IsLower(runes[start-1]) {
+			break
+		}
+	}
+
+	return string(runes[:start]), string(runes[start:])
+}
+
+// Gives an inflected word the case of the original word: all upper case, or
+// starting with an upper case letter
+func matchCase(original, inflected string) string {
+
+	if original == strings.ToUpper(original) && len(original) > 1 {
+		return strings.ToUpper(inflected)
+	}
+
+	runes := []rune(inflected)
+	if len(runes) > 0 && unicode.IsUpper([]rune(original)[0]) {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+
+	return string(runes)
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to matching request paths to the
+// resources of an API definition.
+
+import (
+	"strings"
+)
+
+// GetResource returns the resource whose URI template matches the given
+// request path, relative to the base URI, e.g. the resource /users/{userId}
+// for /users/42. Any query string is ignored. When several resources match,
+// the one with the most literal characters in its template wins, so /users/me
+// is preferred over /users/{userId}. Returns nil when no resource matches.
+// The resource is shared with other callers, so it must not be modified.
+func (api *APIDefinition) GetResource(path string) *Resource {
+	_, resource := api.matchResource(path)
+	return resource
+}
+
+// SpanName returns a name for telemetry about a request, made of its method
+// and the URI template of the resource it was made to, e.g.
+// "GET /users/{userId}" for GET /users/42. Unlike the request path, the
+// template doesn't grow the number of names with every user or ID, so the
+// name suits spans and metric labels. Requests to paths matching no resource
+// are named by their method alone, e.g. "GET".
+func (api *APIDefinition) SpanName(method, path string) string {
+	method = strings.ToUpper(method)
+	if template, resource := api.matchResource(path); resource != nil {
+		return method + " " + template
+	}
+	return method
+}
+
+// Returns the full URI template of the resource best matching the request
+// path, and the resource itself, or nil if there is none
+func (api *APIDefinition) matchResource(path string) (string, *Resource) {
+
+	if query := strings.IndexByte(path, '?'); query >= 0 {
+		path = path[:query]
+	}
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+
+	var matched string
+	var matchedResource *Resource
+	best := -1
+
+	api.walkResources(func(template string, resource *Resource) {
+		if !matchTemplate(template, path) {
+			return
+		}
+		if literals := len(uriParameterRegexp.ReplaceAllString(template,
+			"")); literals > best {
+			matched, matchedResource, best = template, resource, literals
+		}
+	})
+
+	return matched, matchedResource
+}
+
+// Reports whether a path matches a URI template. URI parameters match one or
+// more characters other than slashes, except a trailing {mediaTypeExtension},
+// which matches an optional extension, e.g. .json.
+func matchTemplate(template, path string) bool {
+
+	start := strings.IndexByte(template, '{')
+	if start < 0 {
+		return template == path
+	}
+	end := strings.IndexByte(template[start:], '}')
+	if end < 0 {
+		return template == path
+	}
+	end += start
+
+	literal, name, rest := template[:start], template[start+1:end],
+		template[end+1:]
+	if !strings.HasPrefix(path, literal) {
+		return false
+	}
+	path = path[len(literal):]
+
+	if name == "mediaTypeExtension" && rest == "" {
+		return path == "" || path[0] == '.' && !strings.Contains(path, "/")
+	}
+
+	// Try every length of the value, up to the next slash
+	for length := 1; length <= len(path) && path[length-1] != '/'; length++ {
+		if matchTemplate(rest, path[length:]) {
+			return true
+		}
+	}
+	return false
+}
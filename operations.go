@@ -0,0 +1,203 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to summarizing the operations of an
+// API definition.
+
+import (
+	"sort"
+)
+
+// A summary of a single operation of the API: an HTTP method on a resource,
+// with the parameters needed to call it. Its JSON form is stable, so it can
+// be used by scripts and shell completions.
+type Operation struct {
+
+	// The HTTP method name, e.g. get
+	Method string `json:"method"`
+
+	// The full URI template of the resource, e.g. /users/{userId}
+	Path string `json:"path"`
+
+	// The display name of the resource and the description of the method
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// The parameters of the operation, ordered by name. URI parameters
+	// include those declared by parent resources.
+	UriParameters   []OperationParameter `json:"uriParameters,omitempty"`
+	QueryParameters []OperationParameter `json:"queryParameters,omitempty"`
+	Headers         []OperationParameter `json:"headers,omitempty"`
+
+	// The media types accepted in the request body, ordered
+	MediaTypes []string `json:"mediaTypes,omitempty"`
+
+	// The documented response status codes, ordered
+	StatusCodes []HTTPCode `json:"statusCodes,omitempty"`
+}
+
+// A summary of a named parameter of an operation
+type OperationParameter struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// Returns a summary of every operation of the API, ordered by resource path
+// and then by method.
+func (api *APIDefinition) Operations() []Operation {
+
+	var operations []Operation
+
+	var uris []string
+	for uri := range api.Resources {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		resource := api.Resources[uri]
+		operations = api.resourceOperations(uri, &resource, nil, operations)
+	}
+
+	return operations
+}
+
+// Appends the operations of the resource and its nested resources, given
+// the URI parameters declared by its parents
+func (api *APIDefinition) resourceOperations(path string, resource *Resource,
+	inherited map[string]NamedParameter, operations []Operation) []Operation {
+
+	uriParameters := make(map[string]NamedParameter)
+	for name, parameter := range inherited {
+		uriParameters[name] = parameter
+	}
+	for name, parameter := range resource.UriParameters {
+		uriParameters[name] = parameter
+	}
+
+	// URI parameters are always required
+	var pathParameters []OperationParameter
+	for _, match := range uriParameterRegexp.FindAllString(path, -1) {
+		name := match[1 : len(match)-1]
+		parameter := uriParameters[name]
+		pathParameters = append(pathParameters, OperationParameter{
+			Name:        name,
+			Type:        parameterType(parameter),
+			Required:    true,
+			Description: parameter.Description,
+		})
+	}
+	sort.Slice(pathParameters, func(i, j int) bool {
+		return pathParameters[i].Name < pathParameters[j].Name
+	})
+
+	for _, name := range methodNames {
+
+		method := resource.method(name)
+		if method == nil {
+			continue
+		}
+
+		operation := Operation{
+			Method:          name,
+			Path:            path,
+			DisplayName:     resource.DisplayName,
+			Description:     method.Description,
+			UriParameters:   pathParameters,
+			QueryParameters: operationParameters(method.QueryParameters),
+		}
+
+		headers := make(map[string]NamedParameter)
+		for headerName, header := range method.Headers {
+			headers[string(headerName)] = NamedParameter(header)
+		}
+		operation.Headers = operationParameters(headers)
+
+		if method.Bodies.DefaultSchema != "" ||
+			method.Bodies.DefaultExample != "" ||
+			len(method.Bodies.DefaultFormParameters) > 0 {
+			operation.MediaTypes = append(operation.MediaTypes, api.MediaType)
+		}
+		for mediaType := range method.Bodies.ForMIMEType {
+			operation.MediaTypes = append(operation.MediaTypes, mediaType)
+		}
+		sort.Strings(operation.MediaTypes)
+
+		operation.StatusCodes = sortedCodes(method.Responses, nil)
+
+		operations = append(operations, operation)
+	}
+
+	var uris []string
+	for uri := range resource.Nested {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		operations = api.resourceOperations(path+uri, resource.Nested[uri],
+			uriParameters, operations)
+	}
+
+	return operations
+}
+
+// Summarizes named parameters, ordered by name
+func operationParameters(parameters map[string]NamedParameter) []OperationParameter {
+
+	var names []string
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var summaries []OperationParameter
+	for _, name := range names {
+		parameter := parameters[name]
+		summaries = append(summaries, OperationParameter{
+			Name:        name,
+			Type:        parameterType(parameter),
+			Required:    parameter.Required,
+			Description: parameter.Description,
+		})
+	}
+
+	return summaries
+}
+
+// Returns the type of a named parameter, which is string unless declared
+// otherwise
+func parameterType(parameter NamedParameter) string {
+	if parameter.Type == "" {
+		return "string"
+	}
+	return parameter.Type
+}
@@ -0,0 +1,408 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to resource type and trait parameters.
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Matches a parameter reference in a resource type or trait definition, e.g.
+// <<resourcePathName>> or <<resourcePathName | !singularize>>. The first
+// group is the reference without the brackets.
+var parameterRegexp = regexp.MustCompile(`<<([^<>]*)>>`)
+
+// Parameters whose values are provided by the processing application, and
+// which must not be supplied when applying a resource type or trait
+var reservedResourceTypeParameters = map[string]bool{
+	"resourcePath":     true,
+	"resourcePathName": true,
+}
+var reservedTraitParameters = map[string]bool{
+	"resourcePath":     true,
+	"resourcePathName": true,
+	"methodName":       true,
+}
+
+// A function transforming the value of a parameter, applied by referencing
+// the parameter as <<name | !function>>.
+type ParameterTransform func(value string) string
+
+// The transform functions available to parameter references, by name
+var parameterTransforms = map[string]ParameterTransform{
+	"uppercase":           strings.ToUpper,
+	"lowercase":           strings.ToLower,
+	"lowercamelcase":      lowerCamelCase,
+	"uppercamelcase":      upperCamelCase,
+	"lowerunderscorecase": joinWords("_", strings.ToLower),
+	"upperunderscorecase": joinWords("_", strings.ToUpper),
+	"lowerhyphencase":     joinWords("-", strings.ToLower),
+	"upperhyphencase":     joinWords("-", strings.ToUpper),
+	"singularize":         singularize,
+	"pluralize":           pluralize,
+}
+var parameterTransformsMutex sync.RWMutex
+
+// RegisterParameterTransform makes a transform function available to
+// parameter references under the given name (without the leading !),
+// replacing any function already registered under that name. This lets
+// organizations add their own naming conventions.
+func RegisterParameterTransform(name string, transform ParameterTransform) {
+	parameterTransformsMutex.Lock()
+	defer parameterTransformsMutex.Unlock()
+	parameterTransforms[name] = transform
+}
+
+// Returns the transform function registered under the given name
+func lookupParameterTransform(name string) (ParameterTransform, bool) {
+	parameterTransformsMutex.RLock()
+	defer parameterTransformsMutex.RUnlock()
+	transform, ok := parameterTransforms[name]
+	return transform, ok
+}
+
+// Splits the inside of a parameter reference into the parameter name and
+// the names of the transform functions to apply, in order
+func parseParameterReference(reference string) (string, []string) {
+
+	parts := strings.Split(reference, "|")
+
+	var transforms []string
+	for _, part := range parts[1:] {
+		transforms = append(transforms,
+			strings.TrimPrefix(strings.TrimSpace(part), "!"))
+	}
+
+	return strings.TrimSpace(parts[0]), transforms
+}
+
+// Replaces all parameter references in text with the values of the
+// parameters, transformed as requested.
+func substituteParameters(text string, parameters map[string]string) (string, error) {
+	return substituteParametersKeeping(text, parameters, nil)
+}
+
+// Works like substituteParameters, leaving the references to the parameters
+// to keep as they are when they have no value
+func substituteParametersKeeping(text string, parameters map[string]string,
+	keep map[string]bool) (string, error) {
+
+	var err error
+	substituted := parameterRegexp.ReplaceAllStringFunc(text,
+		func(match string) string {
+
+			name, transforms := parseParameterReference(match[2 : len(match)-2])
+
+			value, ok := parameters[name]
+			if !ok {
+				if keep[name] {
+					return match
+				}
+				if err == nil {
+					err = fmt.Errorf("parameter %s has no value", name)
+				}
+				return match
+			}
+
+			for _, transformName := range transforms {
+				transform, ok := lookupParameterTransform(transformName)
+				if !ok {
+					if err == nil {
+						err = fmt.Errorf("unknown parameter function !%s",
+							transformName)
+					}
+					return match
+				}
+				value = transform(value)
+			}
+
+			return value
+		})
+
+	return substituted, err
+}
+
+// Splits an identifier into words at underscores, hyphens, spaces and case
+// changes, e.g. "userId" and "user_id" both become "user", "id"
+func splitWords(value string) []string {
+
+	var words []string
+	var word []rune
+
+	runes := []rune(value)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || unicode.IsSpace(r):
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+			continue
+		case unicode.IsUpper(r) && len(word) > 0 &&
+			(unicode.IsLower(word[len(word)-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			// userId, or the Id in HTTPId
+			words = append(words, string(word))
+			word = nil
+		}
+		word = append(word, r)
+	}
+
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+
+	return words
+}
+
+// Returns a transform joining the words of a value with a separator, after
+// converting their case
+func joinWords(separator string, convert func(string) string) ParameterTransform {
+	return func(value string) string {
+		return convert(strings.Join(splitWords(value), separator))
+	}
+}
+
+// e.g. userId
+func lowerCamelCase(value string) string {
+	words := splitWords(value)
+	for i, word := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(word)
+		} else {
+			words[i] = capitalize(word)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// e.g. UserId
+func upperCamelCase(value string) string {
+	words := splitWords(value)
+	for i, word := range words {
+		words[i] = capitalize(word)
+	}
+	return strings.Join(words, "")
+}
+
+// Upper cases the first letter of a word and lower cases the rest
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// Returns the references to parameters made by a resource type or trait
+// definition, in both keys and values, without the brackets
+func definitionParameterReferences(definition interface{}) map[string]bool {
+	references := make(map[string]bool)
+	collectParameterReferences(reflect.ValueOf(definition), references)
+	return references
+}
+
+// Returns the names of the parameters referenced by a resource type or trait
+// definition
+func definitionParameterNames(definition interface{}) map[string]bool {
+	names := make(map[string]bool)
+	for reference := range definitionParameterReferences(definition) {
+		name, _ := parseParameterReference(reference)
+		names[name] = true
+	}
+	return names
+}
+
+// Walks a value, adding the parameter references made by any string found
+// in it
+func collectParameterReferences(value reflect.Value, references map[string]bool) {
+
+	switch value.Kind() {
+	case reflect.String:
+		for _, match := range parameterRegexp.FindAllStringSubmatch(value.String(), -1) {
+			references[match[1]] = true
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			collectParameterReferences(value.Elem(), references)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath == "" {
+				collectParameterReferences(value.Field(i), references)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			collectParameterReferences(value.Index(i), references)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			collectParameterReferences(key, references)
+			collectParameterReferences(value.MapIndex(key), references)
+		}
+	}
+}
+
+// Checks that an application of a resource type or trait supplies exactly
+// the parameters its definition references, except the reserved ones.
+// Returns a description of each problem found.
+func checkDefinitionParameters(kind string, choice DefinitionChoice,
+	referenced map[string]bool, reserved map[string]bool) []string {
+
+	var problems []string
+
+	var missing []string
+	for name := range referenced {
+		_, given := choice.Parameters[name]
+		_, invalid := choice.invalid[name]
+		if !given && !invalid && !reserved[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		problems = append(problems, fmt.Sprintf(
+			"%s %s requires parameter %s", kind, choice.Name, name))
+	}
+
+	var unused []string
+	for name := range choice.Parameters {
+		if reserved[name] {
+			problems = append(problems, fmt.Sprintf(
+				"%s %s cannot be given reserved parameter %s, its "+
+					"value is provided when the %s is applied",
+				kind, choice.Name, name, kind))
+		} else if !referenced[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		problems = append(problems, fmt.Sprintf(
+			"%s %s does not use parameter %s", kind, choice.Name, name))
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// Returns a description of each parameter of an application of a resource
+// type or trait given a value of the wrong kind when decoding
+func checkParameterValues(kind string, choice DefinitionChoice) []string {
+
+	var names []string
+	for name := range choice.invalid {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		expected := "a string"
+		if name == "(only)" || name == "(except)" {
+			expected = "a list of method names"
+		}
+		problems = append(problems, fmt.Sprintf(
+			"%s %s must be given %s for parameter %s, not %v", kind,
+			choice.Name, expected, name, choice.invalid[name]))
+	}
+
+	return problems
+}
+
+// Checks the parameters of every resource type and trait application in the
+// API definition. Only the values of the parameters are checked for
+// applications of undeclared resource types and traits. The problems found
+// refer to the nodes of the applications.
+func checkParameters(api *APIDefinition) []ParseError {
+
+	traitParameters := make(map[string]map[string]bool)
+	for _, traits := range api.Traits {
+		for name, trait := range traits {
+			traitParameters[name] = definitionParameterNames(trait)
+		}
+	}
+
+	resourceTypeParameters := make(map[string]map[string]bool)
+	for _, resourceTypes := range api.ResourceTypes {
+		for name, resourceType := range resourceTypes {
+			resourceTypeParameters[name] = definitionParameterNames(resourceType)
+		}
+	}
+
+	var problems []ParseError
+
+	report := func(nodePath string, messages []string) {
+		for _, message := range messages {
+			problems = append(problems, ParseError{NodePath: nodePath,
+				Message: message, Severity: SeverityError,
+				Rule: "definition-parameters"})
+		}
+	}
+
+	checkTraits := func(nodePath string, choices []DefinitionChoice) {
+		for _, choice := range choices {
+			report(nodePath, checkParameterValues("trait", choice))
+			if referenced, ok := traitParameters[choice.Name]; ok {
+				report(nodePath, checkDefinitionParameters("trait",
+					choice, referenced, reservedTraitParameters))
+			}
+		}
+	}
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		if resource.Type != nil {
+			report(nodePath+"/type",
+				checkParameterValues("resource type", *resource.Type))
+			if referenced, ok := resourceTypeParameters[resource.Type.Name]; ok {
+				report(nodePath+"/type", checkDefinitionParameters(
+					"resource type", *resource.Type, referenced,
+					reservedResourceTypeParameters))
+			}
+		}
+
+		checkTraits(nodePath+"/is", resource.Is)
+
+		for _, name := range methodNames {
+			if method := resource.method(name); method != nil {
+				checkTraits(nodePath+"/"+name+"/is", method.Is)
+			}
+		}
+	})
+
+	return problems
+}
@@ -32,37 +32,277 @@ package raml
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	yaml "github.com/advance512/yaml"
 )
 
+// Options controlling the RAML parser. The zero value gives the behaviour
+// of ParseFile.
+type ParserOptions struct {
+
+	// Fail on any !include directive instead of reading the included file.
+	// Useful when parsing untrusted input that must not access local files.
+	DisableIncludes bool
+
+	// Don't check that resource types and traits are given exactly the
+	// parameters their definitions use.
+	SkipParameterChecks bool
+
+	// Only allow including files inside this directory or its
+	// subdirectories, e.g. when parsing specs received from third parties.
+	// Other includes fail with an *IncludeSecurityError. Symbolic links are
+	// followed before checking. Empty means no restriction.
+	IncludeRoot string
+
+	// Stop parsing once more than this many problems were found, instead of
+	// reporting all of them. Preprocessing, decoding and the checks that
+	// follow each stop as soon as the limit is passed, so later phases
+	// don't run at all. Zero means no limit.
+	MaxErrors int
+
+	// Fail parsing on warnings too, e.g. resources without a display name,
+	// instead of returning them in APIDefinition.Warnings
+	WarningsAsErrors bool
+
+	// Return whatever part of the definition could be decoded along with
+	// the *RamlError, instead of nil, e.g. to render the documentation of
+	// partially broken specs. Values that could not be decoded are left
+	// zero. Errors found before decoding, such as a missing RAML version
+	// or unreadable files, still return nil.
+	Lenient bool
+
+	// Receives diagnostic messages, such as the files being included and
+	// the preprocessed document. Nothing is logged when nil.
+	Logger Logger
+
+	// Receives the events of parsing: files included, resources parsed and
+	// issues found. The definition keeps it, so that resolving it later
+	// reports the traits applied too. Nothing is reported when nil.
+	Listener ParseListener
+
+	// Read the RAML file and included files from this file system instead
+	// of the operating system's, e.g. from an embed.FS. Paths are then
+	// slash-separated, as required by fs.FS.
+	FS fs.FS
+
+	// Give up parsing as soon as this context is cancelled or its deadline
+	// expires. The context is checked before every file is read, including
+	// files referenced via !include. When giving up, the context's error is
+	// returned as is. Nil means never giving up.
+	Context context.Context
+}
+
+// A Logger receives diagnostic messages from the parser. It is easily
+// implemented on top of the standard log package or any logging library.
+type Logger interface {
+
+	// Logs messages useful for diagnosing parsing problems, e.g. which
+	// files are being included
+	Debugf(format string, args ...interface{})
+
+	// Logs very verbose output, e.g. the whole preprocessed document
+	Tracef(format string, args ...interface{})
+}
+
+// The Logger used when none is configured, which discards everything
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Tracef(format string, args ...interface{}) {}
+
+// Reports whether enough problems were found to stop parsing. One more
+// than the limit is collected, so that the error notes others were dropped.
+func (options *ParserOptions) tooManyErrors(problems []ParseError) bool {
+	return options.MaxErrors > 0 && len(problems) > options.MaxErrors
+}
+
+// Returns the configured context, or one that is never cancelled
+func (options *ParserOptions) context() context.Context {
+	if options.Context == nil {
+		return context.Background()
+	}
+	return options.Context
+}
+
+// Returns the configured logger, or one discarding everything
+func (options *ParserOptions) logger() Logger {
+	if options.Logger == nil {
+		return nopLogger{}
+	}
+	return options.Logger
+}
+
+// Returned when a RAML document includes a file outside of the include
+// root set in the parser options.
+type IncludeSecurityError struct {
+	Path string
+	Root string
+}
+
+func (e *IncludeSecurityError) Error() string {
+	return fmt.Sprintf("Security error: file %s is outside the include "+
+		"root %s", e.Path, e.Root)
+}
+
+// Checks that an included file lies inside the include root, if there is one
+func (options *ParserOptions) checkIncludeRoot(includedPath string) error {
+
+	if options.IncludeRoot == "" {
+		return nil
+	}
+
+	securityError := &IncludeSecurityError{Path: includedPath,
+		Root: options.IncludeRoot}
+
+	if options.FS != nil {
+		root := path.Clean(options.IncludeRoot)
+		target := path.Clean(includedPath)
+		if root == "." || target == root ||
+			strings.HasPrefix(target, root+"/") {
+			return nil
+		}
+		return securityError
+	}
+
+	root, err := filepath.Abs(options.IncludeRoot)
+	if err != nil {
+		return securityError
+	}
+	target, err := filepath.Abs(includedPath)
+	if err != nil {
+		return securityError
+	}
+
+	// Follow symbolic links, which could otherwise lead outside the root
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+
+	relative, err := filepath.Rel(root, target)
+	if err != nil || relative == ".." ||
+		strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return securityError
+	}
+
+	return nil
+}
+
+// Joins path elements the way the file system in use expects
+func (options *ParserOptions) joinPath(elements ...string) string {
+	if options.FS != nil {
+		return path.Join(elements...)
+	}
+	return filepath.Join(elements...)
+}
+
+// Splits a path into its directory and file name the way the file system
+// in use expects
+func (options *ParserOptions) splitPath(filePath string) (string, string) {
+	if options.FS != nil {
+		return path.Split(filePath)
+	}
+	return filepath.Split(filePath)
+}
+
 // Parse a RAML file. Returns a raml.APIDefinition value or an error if
 // everything is something went wrong.
 // This is the main entry point to the RAML parser.
 func ParseFile(filePath string) (*APIDefinition, error) {
+	return parseFile(filePath, nil)
+}
+
+// Parse a RAML file like ParseFile, configured by the given options. A nil
+// options value gives the default behaviour.
+func ParseFileWithOptions(filePath string,
+	options *ParserOptions) (*APIDefinition, error) {
+	return parseFile(filePath, options)
+}
+
+// Parse a RAML file stored in a file system, e.g. specs embedded in the
+// binary with go:embed. The root is the slash-separated path of the main
+// file within fsys, and files referenced via !include are read from fsys
+// as well.
+func ParseFS(fsys fs.FS, root string) (*APIDefinition, error) {
+	return parseFile(root, &ParserOptions{FS: fsys})
+}
+
+// Parse a RAML file like ParseFile, giving up as soon as the context is
+// cancelled or its deadline expires, as set by ParserOptions.Context.
+func ParseFileContext(ctx context.Context, filePath string) (*APIDefinition, error) {
+	return parseFile(filePath, &ParserOptions{Context: ctx})
+}
+
+// Parse a RAML file, checking the context of the options before reading any
+// file
+func parseFile(filePath string, options *ParserOptions) (*APIDefinition, error) {
+
+	if options == nil {
+		options = new(ParserOptions)
+	}
+
+	if err := options.context().Err(); err != nil {
+		return nil, err
+	}
 
 	// Get the working directory
-	workingDirectory, fileName := filepath.Split(filePath)
+	workingDirectory, fileName := options.splitPath(filePath)
 
 	// Read original file contents into a byte array
-	mainFileBytes, err := readFileContents(workingDirectory, fileName)
+	mainFileBytes, err :=
+		readFileContents(workingDirectory, fileName, options)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Get the contents of the main file
-	mainFileBuffer := bytes.NewBuffer(mainFileBytes)
+	return parseBytes(mainFileBytes, workingDirectory, options)
+}
+
+// Parse RAML contents held in memory, e.g. fetched from a database. Files
+// referenced via !include are read relative to workingDirectory; pass an
+// empty string to use the current directory. Returns a raml.APIDefinition
+// value or an error if something went wrong.
+func ParseBytes(contents []byte, workingDirectory string) (*APIDefinition, error) {
+	return parseBytes(contents, workingDirectory, nil)
+}
+
+// Parse RAML contents held in memory like ParseBytes, configured by the
+// given options, e.g. to restrict the files untrusted contents may include.
+// A nil options value gives the default behaviour.
+func ParseBytesWithOptions(contents []byte, workingDirectory string,
+	options *ParserOptions) (*APIDefinition, error) {
+	return parseBytes(contents, workingDirectory, options)
+}
+
+// Parse RAML contents, checking the context of the options before reading
+// any included file
+func parseBytes(contents []byte, workingDirectory string,
+	options *ParserOptions) (*APIDefinition, error) {
+
+	if options == nil {
+		options = new(ParserOptions)
+	}
+	ctx := options.context()
 
 	// Verify the YAML version
 	var ramlVersion string
-	if firstLine, err := mainFileBuffer.ReadString('\n'); err != nil {
+	if firstLine, err := bytes.NewBuffer(contents).ReadString('\n'); err != nil {
 		return nil, fmt.Errorf("Problem reading RAML file (Error: %s)", err.Error())
 	} else {
 
@@ -81,25 +321,71 @@ func ParseFile(filePath string) (*APIDefinition, error) {
 		}
 	}
 
-	// Pre-process the original file, following !include directive
-	preprocessedContentsBytes, err :=
-		preProcess(mainFileBuffer, workingDirectory)
+	// Pre-process the original file, following !include directive. The
+	// version header is a YAML comment, so it is kept to keep the line
+	// numbers of the main file intact.
+	// Problems that don't stop parsing are collected, to report as many
+	// of them as possible at once.
+	var problems []ParseError
+	preprocessedContentsBytes, locations, err := preProcess(ctx,
+		bytes.NewBuffer(contents), workingDirectory, options, nil, &problems)
 
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		return nil,
-			fmt.Errorf("Error preprocessing RAML file (Error: %s)", err.Error())
+			fmt.Errorf("Error preprocessing RAML file (Error: %w)", err)
+	}
+
+	// Know where problems found later on came from
+	index := newDocumentIndex(preprocessedContentsBytes, locations)
+	for i := range problems {
+		problems[i].NodePath =
+			index.nodePathOf(problems[i].File, problems[i].Line)
+	}
+
+	if options.tooManyErrors(problems) {
+		return parseFailure(nil, problems, options)
+	}
+
+	// The YAML decoder would silently keep the last of duplicate keys
+	for _, duplicate := range index.duplicates {
+		if options.tooManyErrors(problems) {
+			break
+		}
+		first := locations.locate(duplicate.firstLine)
+		firstPlace := fmt.Sprintf("line %d", first.Line)
+		if first.File != "" {
+			firstPlace += " of " + first.File
+		}
+		problems = append(problems, index.resolve(ParseError{
+			Line: duplicate.line,
+			Message: fmt.Sprintf("%s is declared more than once, "+
+				"first on %s", duplicate.key, firstPlace),
+			Severity: SeverityError,
+			Rule:     "duplicate-key",
+		}))
 	}
 
-	//pretty.Println(string(preprocessedContentsBytes))
+	if options.tooManyErrors(problems) {
+		return parseFailure(nil, problems, options)
+	}
+
+	options.logger().Tracef("Preprocessed RAML document:\n%s",
+		preprocessedContentsBytes)
 
 	// Unmarshal into an APIDefinition value
 	apiDefinition := new(APIDefinition)
 	apiDefinition.RAMLVersion = ramlVersion
+	apiDefinition.index = index
+	apiDefinition.listener = options.Listener
 
 	// Go!
 	err = yaml.Unmarshal(preprocessedContentsBytes, apiDefinition)
 
-	// Any errors?
+	// Any errors? The definition is still decoded partially on type
+	// errors, so go on checking it.
 	if err != nil {
 
 		// Create a RAML error value
@@ -107,30 +393,145 @@ func ParseFile(filePath string) (*APIDefinition, error) {
 
 		// Copy the YAML errors into it..
 		if yamlErrors, ok := err.(*yaml.TypeError); ok {
-			populateRAMLError(ramlError, yamlErrors)
+			populateRAMLError(ramlError, yamlErrors, options.logger())
 		} else {
-			// Or just any other error, though this shouldn't happen.
-			ramlError.Errors = append(ramlError.Errors, err.Error())
+			// Or just any other error, e.g. a syntax error.
+			ramlError.Errors = append(ramlError.Errors,
+				convertYAMLError(err.Error()))
 		}
 
-		return nil, ramlError
+		// Point at the lines of the original files, not the preprocessed
+		// document
+		for _, problem := range ramlError.Errors {
+			if options.tooManyErrors(problems) {
+				break
+			}
+			problems = append(problems, index.resolve(problem))
+		}
+	}
+
+	if options.tooManyErrors(problems) {
+		return parseFailure(apiDefinition, problems, options)
+	}
+
+	// Complete the definition, e.g. canonicalize header names
+	for _, problem := range postProcess(apiDefinition) {
+		if options.tooManyErrors(problems) {
+			break
+		}
+		problems = append(problems, index.resolve(problem))
+	}
+
+	apiDefinition.walkResources(func(path string, resource *Resource) {
+		options.Listener.notify(ParseEvent{Kind: EventResourceParsed,
+			ResourcePath: path})
+	})
+
+	// Check the parameters given to resource types and traits, since typos
+	// would otherwise silently leave <<parameters>> unsubstituted
+	if !options.SkipParameterChecks && !options.tooManyErrors(problems) {
+		for _, problem := range checkParameters(apiDefinition) {
+			if options.tooManyErrors(problems) {
+				break
+			}
+			problems = append(problems, index.resolve(problem))
+		}
+	}
+
+	// Report what isn't wrong, but is likely not what the author meant
+	var warnings []ParseError
+	for _, warning := range checkWarnings(apiDefinition) {
+		warnings = append(warnings, index.resolve(warning))
+	}
+
+	if options.WarningsAsErrors {
+		problems = append(problems, warnings...)
+	} else {
+		apiDefinition.Warnings = warnings
+		for _, warning := range warnings {
+			options.Listener.notify(ParseEvent{Kind: EventIssueFound,
+				Issue: warning})
+		}
+	}
+
+	if len(problems) > 0 {
+		return parseFailure(apiDefinition, problems, options)
 	}
 
 	// Good.
 	return apiDefinition, nil
 }
 
-// Reads the contents of a file, returns a bytes buffer
-func readFileContents(workingDirectory string, fileName string) ([]byte, error) {
+// Returns the problems found parsing the definition, along with the
+// definition itself when parsing leniently
+func parseFailure(apiDefinition *APIDefinition, problems []ParseError,
+	options *ParserOptions) (*APIDefinition, error) {
+
+	ramlError := newRamlError(problems, options.MaxErrors)
+	notifyIssues(ramlError, options)
+
+	if options.Lenient {
+		return apiDefinition, ramlError
+	}
+	return nil, ramlError
+}
+
+// Passes the problems of a RamlError to the listener of the options
+func notifyIssues(ramlError *RamlError, options *ParserOptions) {
+	for _, problem := range ramlError.Errors {
+		options.Listener.notify(ParseEvent{Kind: EventIssueFound,
+			Issue: problem})
+	}
+}
 
-	filePath := filepath.Join(workingDirectory, fileName)
+// Parse RAML contents held in a string. Works like ParseBytes.
+func ParseString(contents string, workingDirectory string) (*APIDefinition, error) {
+	return ParseBytes([]byte(contents), workingDirectory)
+}
+
+// Parse RAML contents held in a string. Works like ParseBytesWithOptions.
+func ParseStringWithOptions(contents string, workingDirectory string,
+	options *ParserOptions) (*APIDefinition, error) {
+	return ParseBytesWithOptions([]byte(contents), workingDirectory, options)
+}
+
+// Parse RAML contents read from a reader, e.g. an HTTP request body, without
+// writing them to a file first. Works like ParseBytes.
+func ParseReader(r io.Reader, workingDirectory string) (*APIDefinition, error) {
+	return ParseReaderWithOptions(r, workingDirectory, nil)
+}
+
+// Parse RAML contents read from a reader. Works like ParseBytesWithOptions.
+func ParseReaderWithOptions(r io.Reader, workingDirectory string,
+	options *ParserOptions) (*APIDefinition, error) {
+
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Problem reading RAML (Error: %s)", err.Error())
+	}
+
+	return ParseBytesWithOptions(contents, workingDirectory, options)
+}
+
+// Reads the contents of a file, from the file system given in the options
+// if there is one, returns a bytes buffer
+func readFileContents(workingDirectory string, fileName string,
+	options *ParserOptions) ([]byte, error) {
+
+	filePath := options.joinPath(workingDirectory, fileName)
 
 	if fileName == "" {
 		return nil, fmt.Errorf("File name cannot be nil: %s", filePath)
 	}
 
 	// Read the file
-	fileContentsArray, err := ioutil.ReadFile(filePath)
+	var fileContentsArray []byte
+	var err error
+	if options.FS != nil {
+		fileContentsArray, err = fs.ReadFile(options.FS, filePath)
+	} else {
+		fileContentsArray, err = ioutil.ReadFile(filePath)
+	}
 	if err != nil {
 		return nil,
 			fmt.Errorf("Could not read file %s (Error: %s)",
@@ -140,9 +541,128 @@ func readFileContents(workingDirectory string, fileName string) ([]byte, error)
 	return fileContentsArray, nil
 }
 
+// Checks whether an included file is itself YAML, and may therefore
+// include other files
+func isYAMLFile(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".raml", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// Matches a line starting a YAML block scalar, e.g. "description: |"
+var blockScalarRegexp = regexp.MustCompile(`(^|[\s:-])[|>][-+0-9]*\s*(#.*)?$`)
+
+// Matches an !include tag at the start of a value, e.g. "schema: !include
+// user.json" or "- !include item.raml". The first group is everything
+// before the tag, the second the name of the included file.
+var includeRegexp = regexp.MustCompile(
+	`^(\s*(?:-\s+)*(?:(?:"[^"]*"|'[^']*'|[^\s#'"{\[][^#]*?):\s+)?)` +
+		`!include\s+(\S.*?)(?:\s+#.*)?\s*$`)
+
+// Tracks whether the lines of a YAML document, fed to it in order, are
+// the contents of a block scalar
+type blockScalarTracker struct {
+
+	// Whether the previous lines started or continued a block scalar
+	inside bool
+
+	// The indentation of the line starting the block scalar
+	indentation int
+}
+
+// Reports whether the line is part of the contents of a block scalar
+func (tracker *blockScalarTracker) contains(line string) bool {
+
+	trimmed := strings.TrimSpace(line)
+	indentation := len(line) - len(strings.TrimLeft(line, " "))
+
+	if tracker.inside {
+		if trimmed == "" || indentation > tracker.indentation {
+			return true
+		}
+		tracker.inside = false
+	}
+
+	if !strings.HasPrefix(trimmed, "#") && blockScalarRegexp.MatchString(line) {
+		tracker.inside = true
+		tracker.indentation = indentation
+	}
+
+	return false
+}
+
+// Blanks out comment-only lines of an included RAML or YAML fragment,
+// including its #%RAML version header, so they aren't inlined into the
+// including document. The lines are emptied rather than removed to keep the
+// line numbers of the fragment intact. Lines inside block scalars are kept,
+// since they may well start with #, e.g. Markdown headings.
+func stripYAMLComments(contents []byte) []byte {
+
+	var stripped bytes.Buffer
+	var blockScalars blockScalarTracker
+
+	scanner := bufio.NewScanner(bytes.NewBuffer(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !blockScalars.contains(line) &&
+			strings.HasPrefix(strings.TrimSpace(line), "#") {
+			stripped.WriteByte('\n')
+			continue
+		}
+
+		stripped.WriteString(line)
+		stripped.WriteByte('\n')
+	}
+
+	return stripped.Bytes()
+}
+
+// Included files with these extensions are always inlined as text
+var textFileExtensions = map[string]bool{
+	".raml": true,
+	".yaml": true,
+	".yml":  true,
+	".txt":  true,
+	".md":   true,
+	".json": true,
+}
+
+// Checks whether an included file can be inlined as text. Besides files with
+// well known text extensions, files whose contents are UTF-8 text without
+// control characters are considered text too, e.g. JSON schemas saved with
+// a .schema extension.
+func isTextFile(fileName string, contents []byte) bool {
+
+	if textFileExtensions[strings.ToLower(filepath.Ext(fileName))] {
+		return true
+	}
+
+	if !utf8.Valid(contents) {
+		return false
+	}
+
+	for _, r := range string(contents) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // preProcess acts as a preprocessor for a RAML document in YAML format,
-// including files referenced via !include. It returns a pre-processed document.
-func preProcess(originalContents io.Reader, workingDirectory string) ([]byte, error) {
+// including files referenced via !include. Included RAML and YAML files are
+// preprocessed as well, relative to their own directory. includeChain holds
+// the paths of the files currently being included, to detect cycles.
+// It returns a pre-processed document, and where each of its lines came from.
+// Included files that can't be read are left empty and reported in
+// problems, so that the rest of the document can still be checked.
+func preProcess(ctx context.Context, originalContents io.Reader,
+	workingDirectory string, options *ParserOptions,
+	includeChain []string, problems *[]ParseError) ([]byte, sourceMap, error) {
 
 	// NOTE: Since YAML doesn't support !include directives, and since go-yaml
 	// does NOT play nice with !include tags, this has to be done like this.
@@ -150,53 +670,170 @@ func preProcess(originalContents io.Reader, workingDirectory string) ([]byte, er
 	// tags, to add support for !include, but for now - this method is
 	// GoodEnough(TM) and since it will only happen once, I am not prematurely
 	// optimizing it.
+	// The tags are recognized where YAML would see them - at the start of a
+	// block mapping value or sequence entry - but not inside flow mappings
+	// or sequences, e.g. [ !include a.raml ], which would need the decoder
+	// itself to resolve them.
 
 	var preprocessedContents bytes.Buffer
+	var locations sourceMap
+
+	// The file being preprocessed, empty for the main document
+	var currentFile string
+	if len(includeChain) > 0 {
+		currentFile = includeChain[len(includeChain)-1]
+	}
 
 	// Go over each line, looking for !include tags
 	scanner := bufio.NewScanner(originalContents)
 	var line string
+	lineNumber := 0
+	var blockScalars blockScalarTracker
 
 	// Scan the file until we reach EOF or error out
 	for scanner.Scan() {
 		line = scanner.Text()
+		lineNumber++
+		currentLocation := sourceLocation{File: currentFile, Line: lineNumber}
+
+		// Did we find an !include tag to handle? Only values can be
+		// tagged, so mentions of !include in block scalars, quoted strings
+		// and comments are left alone.
+		var match []string
+		if !blockScalars.contains(line) {
+			match = includeRegexp.FindStringSubmatch(line)
+		}
 
-		// Did we find an !include directive to handle?
-		if idx := strings.Index(line, "!include"); idx != -1 {
+		if match != nil {
 
-			// TODO: Do this better
-			includeLength := len("!include ")
+			idx := len(match[1])
+			includedFile := match[2]
 
-			includedFile := line[idx+includeLength:]
+			if options.DisableIncludes {
+				return nil, nil, fmt.Errorf("Cannot include file %s, includes "+
+					"are disabled", includedFile)
+			}
 
 			preprocessedContents.Write([]byte(line[:idx]))
 
+			// Don't read any more files once there are too many problems
+			// to report anyway
+			if options.tooManyErrors(*problems) {
+				preprocessedContents.WriteByte('\n')
+				locations = append(locations, currentLocation)
+				continue
+			}
+
+			// Stop if the caller gave up
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+
+			// Refuse to read files outside the include root
+			includedPath := options.joinPath(workingDirectory, includedFile)
+			if err := options.checkIncludeRoot(includedPath); err != nil {
+				return nil, nil, err
+			}
+
+			options.logger().Debugf("Including file %s", includedPath)
+
 			// Get the included file contents
 			includedContents, err :=
-				readFileContents(workingDirectory, includedFile)
+				readFileContents(workingDirectory, includedFile, options)
 
 			if err != nil {
-				return nil,
-					fmt.Errorf("Error including file %s:\n    %s",
-						includedFile, err.Error())
+				*problems = append(*problems, ParseError{
+					File:   currentFile,
+					Line:   lineNumber,
+					Column: idx + 1,
+					Message: fmt.Sprintf("Error including file %s "+
+						"(Error: %s)", includedFile, err.Error()),
+					Severity: SeverityError,
+				})
+				preprocessedContents.WriteByte('\n')
+				locations = append(locations, currentLocation)
+				continue
 			}
 
-			// TODO: Check that you only insert .yaml, .raml, .txt and .md files
-			// In case of .raml or .yaml, remove the comments
-			// In case of other files, Base64 them first.
+			options.Listener.notify(ParseEvent{Kind: EventFileIncluded,
+				File: includedPath})
+
+			// Indent by this much
+			firstLine := true
+			indentationString := ""
+
+			// Where the lines of the included contents came from, if not
+			// simply from the included file
+			var includedLocations sourceMap
+
+			if isYAMLFile(includedFile) {
+
+				// Resolve the includes of the included file relative to
+				// its own directory, refusing to go around in circles
+				for _, includingPath := range includeChain {
+					if includingPath == includedPath {
+						return nil, nil, fmt.Errorf("Circular include of file %s",
+							includedPath)
+					}
+				}
+
+				includedDirectory, _ := options.splitPath(includedPath)
+				includedContents, includedLocations, err = preProcess(ctx,
+					bytes.NewBuffer(stripYAMLComments(includedContents)),
+					includedDirectory,
+					options, append(includeChain, includedPath), problems)
+
+				if err != nil {
+					return nil, nil,
+						fmt.Errorf("Error including file %s:\n    %w",
+							includedFile, err)
+				}
+
+				// A YAML fragment can't start on the line of its key, so
+				// write it as a block below it
+				preprocessedContents.WriteByte('\n')
+				locations = append(locations, currentLocation)
+				indentationString = strings.Repeat(" ", idx)
+				firstLine = false
+
+			} else if strings.ToLower(filepath.Ext(includedFile)) == ".json" {
+
+				// JSON spans lines and is full of YAML indicators, so
+				// write it as a literal block scalar below its key
+				preprocessedContents.WriteString("|-\n")
+				locations = append(locations, currentLocation)
+				indentationString = strings.Repeat(" ", idx)
+				firstLine = false
+
+			} else if !isTextFile(includedFile, includedContents) {
+
+				// Binary files, e.g. images, can't be inlined as they are
+				includedContents = []byte(
+					base64.StdEncoding.EncodeToString(includedContents))
+			}
 
 			// TODO: Better, step by step checks .. though prolly it'll panic
 			// Write text files in the same indentation as the first line
 			internalScanner :=
 				bufio.NewScanner(bytes.NewBuffer(includedContents))
 
-			// Indent by this much
-			firstLine := true
-			indentationString := ""
-
 			// Go over each line, write it
+			internalLineNumber := 0
 			for internalScanner.Scan() {
 				internalLine := internalScanner.Text()
+				internalLineNumber++
+
+				// The first line of a text file shares the line of its key
+				location := sourceLocation{File: includedPath,
+					Line: internalLineNumber}
+				if internalLineNumber <= len(includedLocations) {
+					location = includedLocations[internalLineNumber-1]
+				}
+				location.Indentation += len(indentationString)
+				if firstLine {
+					location = currentLocation
+				}
+				locations = append(locations, location)
 
 				preprocessedContents.WriteString(indentationString)
 				if firstLine {
@@ -208,19 +845,26 @@ func preProcess(originalContents io.Reader, workingDirectory string) ([]byte, er
 				preprocessedContents.WriteByte('\n')
 			}
 
+			// Don't leave an empty file's key without its line break
+			if firstLine {
+				preprocessedContents.WriteByte('\n')
+				locations = append(locations, currentLocation)
+			}
+
 		} else {
 
 			// No, just a simple line.. write it
 			preprocessedContents.WriteString(line)
 			preprocessedContents.WriteByte('\n')
+			locations = append(locations, currentLocation)
 		}
 	}
 
 	// Any errors encountered?
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("Error reading YAML file: %s", err.Error())
+		return nil, nil, fmt.Errorf("Error reading YAML file: %s", err.Error())
 	}
 
 	// Return the preprocessed contents
-	return preprocessedContents.Bytes(), nil
+	return preprocessedContents.Bytes(), locations, nil
 }
@@ -0,0 +1,362 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to completing API definitions after
+// decoding them.
+
+import (
+	"fmt"
+	"net/textproto"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Completes a decoded API definition, e.g. canonicalizes header names, fills
+// in the names of nodes and declares implicit URI parameters, and returns
+// the problems found doing so. Problems refer to node paths.
+func postProcess(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	headersType := reflect.TypeOf(map[HTTPHeader]Header{})
+
+	walkNodes(reflect.ValueOf(api), "", func(nodePath string, value reflect.Value) {
+		if value.Type() == headersType && !value.IsNil() {
+			headers := value.Interface().(map[HTTPHeader]Header)
+			for _, duplicates := range canonicalizeHeaders(headers) {
+				for _, duplicate := range duplicates[1:] {
+					report("duplicate-header", nodePath+"/"+duplicate,
+						"header %s is also declared as %s", duplicate,
+						duplicates[0])
+				}
+			}
+		}
+
+		fillNames(value)
+	})
+
+	// Names declared more than once can't be looked up
+	_, duplicates := newDeclarations(api)
+	problems = append(problems, duplicates...)
+
+	// Parents are linked last, as top-level resources are replaced until
+	// then
+	fillUriParameters(api)
+	linkResources(api)
+
+	return problems
+}
+
+// Calls visit for a value and everything it contains, with the path of its
+// node in the RAML document, as told by the yaml tags of struct fields.
+func walkNodes(value reflect.Value, nodePath string,
+	visit func(nodePath string, value reflect.Value)) {
+
+	visit(nodePath, value)
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			walkNodes(value.Elem(), nodePath, visit)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+
+			field := value.Type().Field(i)
+			tag := field.Tag.Get("yaml")
+			if field.PkgPath != "" || tag == "-" {
+				continue
+			}
+
+			// Fields holding the mapping's other keys, e.g. nested
+			// resources, don't have a node of their own. Top-level
+			// resources are kept apart from the other properties of the
+			// API definition, though.
+			name := strings.Split(tag, ",")[0]
+			fieldPath := nodePath + "/" + name
+			switch {
+			case value.Type() == reflect.TypeOf(APIDefinition{}) &&
+				field.Name == "Resources":
+				fieldPath = nodePath + "/resources"
+			case strings.Contains(tag, ",regexp:"):
+				fieldPath = nodePath
+			case name == "":
+				fieldPath = nodePath + "/" + strings.ToLower(field.Name)
+			}
+
+			walkNodes(value.Field(i), fieldPath, visit)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			walkNodes(value.Index(i), nodePath, visit)
+		}
+
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keyString(keys[i]) < keyString(keys[j])
+		})
+		for _, key := range keys {
+			walkNodes(value.MapIndex(key),
+				nodePath+"/"+keyString(key), visit)
+		}
+	}
+}
+
+// Declares the URI parameters of every resource which its relative URI uses
+// but neither it nor its resource type declare, whether in uriParameters or
+// uriParameters?. As the spec requires, these
+// are required strings, displayed under their names. The reserved version
+// and mediaTypeExtension parameters are left out.
+func fillUriParameters(api *APIDefinition) {
+	for uri, resource := range api.Resources {
+		fillResourceUriParameters(api, uri, &resource)
+		api.Resources[uri] = resource
+	}
+}
+
+// Declares the implicit URI parameters of a resource with the given relative
+// URI, and those of its nested resources
+func fillResourceUriParameters(api *APIDefinition, uri string,
+	resource *Resource) {
+
+	// Declared by the resource type, whether required or optional
+	inherited := make(map[string]NamedParameter)
+	if resource.Type != nil {
+		if resourceType, ok := api.GetResourceType(resource.Type.Name); ok {
+			for name, parameter := range resourceType.OptionalUriParameters {
+				inherited[name] = parameter
+			}
+			for name, parameter := range resourceType.UriParameters {
+				inherited[name] = parameter
+			}
+		}
+	}
+
+	for _, match := range uriParameterRegexp.FindAllString(uri, -1) {
+
+		// The reserved parameters aren't parameters of the resource: the
+		// version comes from the root, and a trailing mediaTypeExtension
+		// is an optional extension, e.g. .json
+		name := match[1 : len(match)-1]
+		if name == "version" || name == "mediaTypeExtension" &&
+			strings.HasSuffix(uri, match) {
+			continue
+		}
+
+		if _, ok := resource.UriParameters[name]; ok {
+			continue
+		}
+		if _, ok := inherited[name]; ok {
+			continue
+		}
+
+		if resource.UriParameters == nil {
+			resource.UriParameters = make(map[string]NamedParameter)
+		}
+		resource.UriParameters[name] = NamedParameter{
+			Name:        name,
+			DisplayName: name,
+			Type:        "string",
+			Required:    true,
+		}
+	}
+
+	for nestedURI, nested := range resource.Nested {
+		if nested != nil {
+			fillResourceUriParameters(api, nestedURI, nested)
+		}
+	}
+}
+
+// Sets the URI and parent of every resource in the API definition
+func linkResources(api *APIDefinition) {
+	for uri, resource := range api.Resources {
+
+		// Nested resources need a parent that outlives the loop
+		parent := new(Resource)
+		*parent = resource
+		parent.URI = uri
+		parent.Parent = nil
+		linkNestedResources(parent)
+
+		api.Resources[uri] = *parent
+	}
+}
+
+// Sets the URI of the nested resources of a resource, and their parent to it
+func linkNestedResources(resource *Resource) {
+	for uri, nested := range resource.Nested {
+		if nested != nil {
+			nested.URI = uri
+			nested.Parent = resource
+			linkNestedResources(nested)
+		}
+	}
+}
+
+// Clears the parent of every resource in the API definition, as encoders
+// can't follow the cycles they make
+func unlinkResources(api *APIDefinition) {
+	for uri, resource := range api.Resources {
+		unlinkNestedResources(&resource)
+		api.Resources[uri] = resource
+	}
+}
+
+// Clears the parent of the nested resources of a resource
+func unlinkNestedResources(resource *Resource) {
+	resource.Parent = nil
+	for _, nested := range resource.Nested {
+		if nested != nil {
+			unlinkNestedResources(nested)
+		}
+	}
+}
+
+// The fields holding the keys of nodes, by order of preference
+var nameFields = []string{"Name", "HTTPCode"}
+
+// Sets the names of the values of a mapping, e.g. of named parameters,
+// traits and responses, to their keys, and those of the methods of a
+// resource or resource type to the method names
+func fillNames(value reflect.Value) {
+
+	switch value.Kind() {
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+
+			element := value.MapIndex(key)
+			if element.Kind() == reflect.Ptr {
+				if !element.IsNil() {
+					setName(element.Elem(), key)
+				}
+				continue
+			}
+
+			// Values held in maps can't be changed in place
+			named := reflect.New(element.Type()).Elem()
+			named.Set(element)
+			if setName(named, key) {
+				value.SetMapIndex(key, named)
+			}
+		}
+
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+
+			// Optional methods of resource types are named after the
+			// method itself, e.g. get rather than get?
+			tag := value.Type().Field(i).Tag.Get("yaml")
+			name := strings.TrimSuffix(strings.Split(tag, ",")[0], "?")
+
+			field := value.Field(i)
+			if isMethodName(name) && field.Kind() == reflect.Ptr &&
+				!field.IsNil() {
+				setName(field.Elem(), reflect.ValueOf(name))
+			}
+		}
+	}
+}
+
+// Sets the field holding the key of a node, if it has one of the key's
+// kind. Reports whether it was set.
+func setName(value reflect.Value, key reflect.Value) bool {
+
+	if value.Kind() != reflect.Struct {
+		return false
+	}
+
+	for _, fieldName := range nameFields {
+		field := value.FieldByName(fieldName)
+		if field.IsValid() && field.CanSet() && field.Kind() == key.Kind() {
+			field.Set(key.Convert(field.Type()))
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether name is the name of an HTTP method RAML supports
+func isMethodName(name string) bool {
+	for _, methodName := range methodNames {
+		if name == methodName {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a map key as written in the RAML document
+func keyString(key reflect.Value) string {
+	return fmt.Sprint(key.Interface())
+}
+
+// Renames headers to their canonical form, e.g. content-type to
+// Content-Type. Returns the names of the headers declared more than once
+// in different cases, in groups of the same header. Only the declaration
+// under the first name of each group is kept.
+func canonicalizeHeaders(headers map[HTTPHeader]Header) [][]string {
+
+	names := make(map[HTTPHeader][]string)
+	for name := range headers {
+		canonical := HTTPHeader(textproto.CanonicalMIMEHeaderKey(string(name)))
+		names[canonical] = append(names[canonical], string(name))
+	}
+
+	var canonicals []string
+	for canonical := range names {
+		canonicals = append(canonicals, string(canonical))
+	}
+	sort.Strings(canonicals)
+
+	var duplicates [][]string
+	for _, canonical := range canonicals {
+
+		declared := names[HTTPHeader(canonical)]
+		sort.Strings(declared)
+
+		header := headers[HTTPHeader(declared[0])]
+		for _, name := range declared {
+			delete(headers, HTTPHeader(name))
+		}
+		headers[HTTPHeader(canonical)] = header
+
+		if len(declared) > 1 {
+			duplicates = append(duplicates, declared)
+		}
+	}
+
+	return duplicates
+}
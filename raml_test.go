@@ -30,8 +30,23 @@ package raml
 // This file contains tests.
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+
+	yaml "github.com/advance512/yaml"
 )
 
 // TODO: Way, way more serious tests.
@@ -90,3 +105,3134 @@ func TestParsing(t *testing.T) {
 		// 	pretty.Println(apiDefinition)
 	}
 }
+
+func TestSnapshot(t *testing.T) {
+
+	fileName := "./samples/congo/api.raml"
+
+	apiDefinition, err := ParseFile(fileName)
+	if err != nil {
+		t.Fatalf("Failed parsing file %s:\n  %s", fileName, err.Error())
+	}
+
+	var snapshot bytes.Buffer
+	if err = apiDefinition.Save(&snapshot); err != nil {
+		t.Fatalf("Failed saving snapshot of %s: %s", fileName, err.Error())
+	}
+
+	loaded, err := Load(&snapshot)
+	if err != nil {
+		t.Fatalf("Failed loading snapshot of %s: %s", fileName, err.Error())
+	}
+
+	if loaded.Title != apiDefinition.Title ||
+		len(loaded.Resources) != len(apiDefinition.Resources) {
+		t.Fatalf("Snapshot of %s does not match the parsed definition",
+			fileName)
+	}
+
+	if _, err = Load(bytes.NewBufferString("not a snapshot")); err == nil {
+		t.Fatalf("Failed detecting a bad snapshot")
+	}
+
+	// Version 1 snapshots hold numbers as float64s, which don't decode
+	var old bytes.Buffer
+	gob.NewEncoder(&old).Encode(&snapshotHeader{snapshotMagic, 1})
+	if _, err = Load(&old); err == nil ||
+		!strings.Contains(err.Error(), "version 1 is no longer supported") {
+		t.Fatalf("Failed refusing an old snapshot: %v", err)
+	}
+}
+
+func TestNumberPrecision(t *testing.T) {
+
+	var parameter NamedParameter
+	err := yaml.Unmarshal([]byte("minimum: 9007199254740993\nmaximum: 0.10\n"),
+		&parameter)
+	if err != nil {
+		t.Fatalf("Failed unmarshaling numbers: %s", err.Error())
+	}
+
+	if parameter.Minimum.Rat().String() != "9007199254740993/1" {
+		t.Fatalf("Minimum lost precision: %s", parameter.Minimum.Rat())
+	}
+
+	if parameter.Maximum.Rat().String() != "1/10" {
+		t.Fatalf("Maximum lost precision: %s", parameter.Maximum.Rat())
+	}
+
+	for _, minimum := range []string{"lots", `"5"`, "1/3"} {
+		err = yaml.Unmarshal([]byte("minimum: "+minimum+"\n"), &parameter)
+		if err == nil {
+			t.Fatalf("Failed detecting a non-numeric minimum %s", minimum)
+		}
+	}
+}
+
+func TestRouteConflicts(t *testing.T) {
+
+	apiDefinition := &APIDefinition{
+		Resources: map[string]Resource{
+			"/users": {
+				Nested: map[string]*Resource{
+					"/{id}":     {},
+					"/me":       {},
+					"/{userId}": {},
+				},
+			},
+			"/files/{fileId}.json": {},
+			"/files/{fileId}.xml":  {},
+		},
+	}
+
+	conflicts := apiDefinition.RouteConflicts()
+
+	expected := map[string]bool{
+		"/users/me /users/{id}":       true,
+		"/users/me /users/{userId}":   true,
+		"/users/{id} /users/{userId}": true,
+	}
+
+	if len(conflicts) != len(expected) {
+		t.Fatalf("Expected %d route conflicts, got %v", len(expected),
+			conflicts)
+	}
+
+	for _, conflict := range conflicts {
+		if !expected[conflict.First+" "+conflict.Second] {
+			t.Fatalf("Unexpected route conflict: %v", conflict)
+		}
+	}
+}
+
+func TestParseString(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: In Memory API\n"+
+		"/songs:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        body:\n"+
+		"          application/json:\n"+
+		"            schema: !include jukebox-include-song.schema\n",
+		"./samples/raml-tutorial-200")
+
+	if err != nil {
+		t.Fatalf("Failed parsing RAML string:\n  %s", err.Error())
+	}
+
+	if apiDefinition.Title != "In Memory API" {
+		t.Fatalf("Parsed wrong title: %s", apiDefinition.Title)
+	}
+
+	body := apiDefinition.Resources["/songs"].Get.Responses[200].Bodies.
+		ForMIMEType["application/json"]
+	if body.Schema == "" {
+		t.Fatalf("Failed including a schema relative to the working directory")
+	}
+
+	apiDefinition, err = ParseReader(
+		strings.NewReader("#%RAML 0.8\ntitle: Streamed API\n"), "")
+	if err != nil {
+		t.Fatalf("Failed parsing RAML from a reader:\n  %s", err.Error())
+	}
+
+	if apiDefinition.Title != "Streamed API" {
+		t.Fatalf("Parsed wrong title: %s", apiDefinition.Title)
+	}
+
+	if _, err = ParseBytes([]byte("title: Not RAML\n"), ""); err == nil {
+		t.Fatalf("Failed detecting contents without a RAML version")
+	}
+
+	untrusted := "#%RAML 0.8\n" +
+		"title: Untrusted API\n" +
+		"documentation:\n" +
+		"  - title: Secrets\n" +
+		"    content: !include ../../../etc/passwd\n"
+
+	_, err = ParseReaderWithOptions(strings.NewReader(untrusted), "",
+		&ParserOptions{DisableIncludes: true})
+	if err == nil {
+		t.Fatalf("Failed rejecting includes in RAML from a reader")
+	}
+
+	_, err = ParseStringWithOptions(untrusted, "./samples",
+		&ParserOptions{IncludeRoot: "./samples"})
+	var securityError *IncludeSecurityError
+	if !errors.As(err, &securityError) {
+		t.Fatalf("Failed restricting includes in a RAML string: %v", err)
+	}
+}
+
+func TestTraitParameters(t *testing.T) {
+
+	_, err := ParseString("#%RAML 0.8\n"+
+		"title: Parameters API\n"+
+		"traits:\n"+
+		"  - secured:\n"+
+		"      queryParameters:\n"+
+		"        <<tokenName>>:\n"+
+		"          description: A valid <<tokenName>> for <<methodName>>\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    is: [ secured: { tokenNam: access_token, methodName: get } ]\n",
+		"")
+
+	ramlError, ok := err.(*RamlError)
+	if !ok {
+		t.Fatalf("Failed detecting bad trait parameters: %v", err)
+	}
+
+	expected := []string{
+		"line 10: trait secured cannot be given reserved parameter " +
+			"methodName, its value is provided when the trait is applied",
+		"line 10: trait secured does not use parameter tokenNam",
+		"line 10: trait secured requires parameter tokenName",
+	}
+
+	if fmt.Sprint(ramlError.Errors) != fmt.Sprint(expected) ||
+		ramlError.Errors[0].NodePath != "/resources//users/get/is" {
+		t.Fatalf("Unexpected trait parameter errors:\n  %s",
+			ramlError.Error())
+	}
+
+	// Values of the wrong kind don't keep other problems from being found
+	_, err = ParseString("#%RAML 0.8\n"+
+		"title: Parameter Values API\n"+
+		"traits:\n"+
+		"  - paged:\n"+
+		"      description: Pages of <<name>>\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    is: [ paged: { name: [ users ], (only): [ [ get ] ] } ]\n"+
+		"    headers:\n"+
+		"      x:\n"+
+		"      X:\n",
+		"")
+
+	if ramlError, ok = err.(*RamlError); !ok {
+		t.Fatalf("Failed detecting bad trait parameter values: %v", err)
+	}
+
+	var issues []string
+	for _, issue := range ramlError.Errors {
+		issues = append(issues, issue.Rule+": "+issue.Error())
+	}
+
+	expected = []string{
+		"duplicate-header: line 10: header x is also declared as X",
+		"definition-parameters: line 8: trait paged must be given a list " +
+			"of method names for parameter (only), not [[get]]",
+		"definition-parameters: line 8: trait paged must be given a string " +
+			"for parameter name, not [users]",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected parameter value errors %q, got %q", expected,
+			issues)
+	}
+}
+
+func TestParameterTransforms(t *testing.T) {
+
+	RegisterParameterTransform("shout", func(value string) string {
+		return value + "!"
+	})
+
+	parameters := map[string]string{"name": "userAccountId"}
+
+	expected := map[string]string{
+		"<<name>>":                             "userAccountId",
+		"<<name | !uppercase>>":                "USERACCOUNTID",
+		"<<name|!lowercase>>":                  "useraccountid",
+		"<<name | !uppercamelcase>>":           "UserAccountId",
+		"<<name | !lowerunderscorecase>>":      "user_account_id",
+		"<<name | !upperhyphencase>>":          "USER-ACCOUNT-ID",
+		"<<name | !lowerhyphencase | !shout>>": "user-account-id!",
+		"get <<name | !lowercamelcase>>s":      "get userAccountIds",
+	}
+
+	for text, value := range expected {
+		substituted, err := substituteParameters(text, parameters)
+		if err != nil {
+			t.Fatalf("Failed substituting %s: %s", text, err.Error())
+		}
+		if substituted != value {
+			t.Fatalf("Substituted %s into %s instead of %s", text,
+				substituted, value)
+		}
+	}
+
+	if _, err := substituteParameters("<<name | !nonsense>>", parameters); err == nil {
+		t.Fatalf("Failed detecting an unknown parameter function")
+	}
+
+	if _, err := substituteParameters("<<other>>", parameters); err == nil {
+		t.Fatalf("Failed detecting a parameter without a value")
+	}
+}
+
+func TestParseFileContext(t *testing.T) {
+
+	fileName := "./samples/raml-tutorial-200/jukebox-api.raml"
+
+	if _, err := ParseFileContext(context.Background(), fileName); err != nil {
+		t.Fatalf("Failed parsing file %s:\n  %s", fileName, err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ParseFileContext(ctx, fileName); err != context.Canceled {
+		t.Fatalf("Expected cancellation while parsing %s, got: %v",
+			fileName, err)
+	}
+
+	// The context can be given along with any other options
+	_, err := ParseStringWithOptions("#%RAML 0.8\n"+
+		"title: Cancelled API\n"+
+		"schemas:\n"+
+		"  - song: !include jukebox-include-song.schema\n",
+		"./samples/raml-tutorial-200",
+		&ParserOptions{Context: ctx, IncludeRoot: "./samples"})
+	if err != context.Canceled {
+		t.Fatalf("Expected cancellation with other options, got: %v", err)
+	}
+}
+
+func TestParseFileWithOptions(t *testing.T) {
+
+	fileName := "./samples/raml-tutorial-200/jukebox-api.raml"
+
+	if _, err := ParseFileWithOptions(fileName, nil); err != nil {
+		t.Fatalf("Failed parsing file %s:\n  %s", fileName, err.Error())
+	}
+
+	_, err := ParseFileWithOptions(fileName,
+		&ParserOptions{DisableIncludes: true})
+	if err == nil {
+		t.Fatalf("Failed rejecting includes in %s", fileName)
+	}
+}
+
+func TestExamples(t *testing.T) {
+
+	fileName := "./samples/congo/api.raml"
+
+	apiDefinition, err := ParseFile(fileName)
+	if err != nil {
+		t.Fatalf("Failed parsing file %s:\n  %s", fileName, err.Error())
+	}
+
+	examples := apiDefinition.Examples()
+	if len(examples) != 9 {
+		t.Fatalf("Expected 9 example pairs in %s, got %d", fileName,
+			len(examples))
+	}
+
+	pair := examples[1]
+	if pair.ResourcePath != "/deliveries" || pair.Method != "post" ||
+		pair.StatusCode != 201 || pair.RequestExample == "" ||
+		pair.ResponseExample == "" ||
+		pair.ResponseMediaType != "application/json" {
+		t.Fatalf("Unexpected example pair: %+v", pair)
+	}
+}
+
+func TestParseFS(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"specs/api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Embedded API\n" +
+			"documentation:\n" +
+			" - title: Introduction\n" +
+			"   content: !include docs/intro.md\n")},
+		"specs/docs/intro.md": &fstest.MapFile{
+			Data: []byte("Welcome to the embedded API.\n")},
+	}
+
+	apiDefinition, err := ParseFS(fsys, "specs/api.raml")
+	if err != nil {
+		t.Fatalf("Failed parsing from a file system:\n  %s", err.Error())
+	}
+
+	if apiDefinition.Documentation[0].Content != "Welcome to the embedded API." {
+		t.Fatalf("Failed including a file from the file system: %v",
+			apiDefinition.Documentation)
+	}
+
+	if _, err = ParseFS(fsys, "specs/missing.raml"); err == nil {
+		t.Fatalf("Failed detecting a missing file in the file system")
+	}
+}
+
+func TestRecursiveIncludes(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"specs/api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Nested Includes API\n" +
+			"/users:\n" +
+			"  get: !include methods/get-users.raml\n")},
+		"specs/methods/get-users.raml": &fstest.MapFile{Data: []byte(
+			"description: List users\n" +
+				"responses:\n" +
+				"  200:\n" +
+				"    body:\n" +
+				"      text/plain:\n" +
+				"        example: !include ../examples/users.txt\n")},
+		"specs/examples/users.txt": &fstest.MapFile{
+			Data: []byte("alice, bob\n")},
+		"specs/loop.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: !include a.raml\n")},
+		"specs/a.raml": &fstest.MapFile{Data: []byte("!include b.raml\n")},
+		"specs/b.raml": &fstest.MapFile{Data: []byte("!include a.raml\n")},
+	}
+
+	apiDefinition, err := ParseFS(fsys, "specs/api.raml")
+	if err != nil {
+		t.Fatalf("Failed parsing nested includes:\n  %s", err.Error())
+	}
+
+	get := apiDefinition.Resources["/users"].Get
+	if get == nil || get.Description != "List users" {
+		t.Fatalf("Failed including a RAML fragment: %+v", get)
+	}
+
+	example := get.Responses[200].Bodies.ForMIMEType["text/plain"].Example
+	if example != "alice, bob" {
+		t.Fatalf("Failed resolving a nested include relative to its file: %q",
+			example)
+	}
+
+	if _, err = ParseFS(fsys, "specs/loop.raml"); err == nil ||
+		!strings.Contains(err.Error(), "Circular include") {
+		t.Fatalf("Failed detecting circular includes: %v", err)
+	}
+}
+
+func TestIncludeRoot(t *testing.T) {
+
+	directory, err := ioutil.TempDir("", "raml")
+	if err != nil {
+		t.Fatalf("Failed creating a temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(directory)
+
+	specs := filepath.Join(directory, "specs")
+	files := map[string]string{
+		filepath.Join(specs, "api.raml"): "#%RAML 0.8\n" +
+			"title: !include title.txt\n",
+		filepath.Join(specs, "evil.raml"): "#%RAML 0.8\n" +
+			"title: !include ../secret.txt\n",
+		filepath.Join(specs, "title.txt"):      "Sandboxed API\n",
+		filepath.Join(directory, "secret.txt"): "hunter2\n",
+	}
+
+	if err = os.Mkdir(specs, 0755); err != nil {
+		t.Fatalf("Failed creating %s: %s", specs, err.Error())
+	}
+	for fileName, contents := range files {
+		if err = ioutil.WriteFile(fileName, []byte(contents), 0644); err != nil {
+			t.Fatalf("Failed writing %s: %s", fileName, err.Error())
+		}
+	}
+
+	options := &ParserOptions{IncludeRoot: specs}
+
+	apiDefinition, err := ParseFileWithOptions(
+		filepath.Join(specs, "api.raml"), options)
+	if err != nil {
+		t.Fatalf("Failed parsing a sandboxed file:\n  %s", err.Error())
+	}
+	if apiDefinition.Title != "Sandboxed API" {
+		t.Fatalf("Parsed wrong title: %s", apiDefinition.Title)
+	}
+
+	_, err = ParseFileWithOptions(filepath.Join(specs, "evil.raml"), options)
+	var securityError *IncludeSecurityError
+	if !errors.As(err, &securityError) {
+		t.Fatalf("Failed detecting an include outside the root: %v", err)
+	}
+}
+
+func TestBinaryIncludes(t *testing.T) {
+
+	image := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0xff}
+
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Binary API\n" +
+			"/avatar:\n" +
+			"  get:\n" +
+			"    responses:\n" +
+			"      200:\n" +
+			"        body:\n" +
+			"          image/png:\n" +
+			"            example: !include avatar.png\n")},
+		"avatar.png": &fstest.MapFile{Data: image},
+	}
+
+	apiDefinition, err := ParseFS(fsys, "api.raml")
+	if err != nil {
+		t.Fatalf("Failed parsing a binary include:\n  %s", err.Error())
+	}
+
+	example := apiDefinition.Resources["/avatar"].Get.Responses[200].Bodies.
+		ForMIMEType["image/png"].Example
+	if example != base64.StdEncoding.EncodeToString(image) {
+		t.Fatalf("Failed base64 encoding a binary include: %q", example)
+	}
+}
+
+func TestJSONIncludes(t *testing.T) {
+
+	schema := "{\n" +
+		"  \"type\": \"object\",\n" +
+		"  \"properties\": {\n" +
+		"    \"name\": { \"type\": \"string\" }\n" +
+		"  }\n" +
+		"}"
+
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: JSON API\n" +
+			"schemas:\n" +
+			" - user: !include user.json\n" +
+			"/users:\n" +
+			"  post:\n" +
+			"    body:\n" +
+			"      application/json:\n" +
+			"        schema: !include user.json\n")},
+		"user.json": &fstest.MapFile{Data: []byte(schema + "\n")},
+	}
+
+	apiDefinition, err := ParseFS(fsys, "api.raml")
+	if err != nil {
+		t.Fatalf("Failed parsing JSON includes:\n  %s", err.Error())
+	}
+
+	if apiDefinition.Schemas[0]["user"] != schema {
+		t.Fatalf("Failed including a root JSON schema: %q",
+			apiDefinition.Schemas[0]["user"])
+	}
+
+	body := apiDefinition.Resources["/users"].Post.Bodies.
+		ForMIMEType["application/json"]
+	if body.Schema != schema {
+		t.Fatalf("Failed including a body JSON schema: %q", body.Schema)
+	}
+}
+
+func TestIncludedFragments(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Fragments API\n" +
+			"/users:\n" +
+			"  get: !include get-users.raml\n")},
+		"get-users.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"# Lists users. Was: !include old-get-users.raml\n" +
+			"description: |\n" +
+			"  # Users\n" +
+			"  Lists all users.\n" +
+			"# The responses\n" +
+			"responses:\n" +
+			"  200:\n" +
+			"    description: OK\n")},
+	}
+
+	apiDefinition, err := ParseFS(fsys, "api.raml")
+	if err != nil {
+		t.Fatalf("Failed parsing a RAML fragment:\n  %s", err.Error())
+	}
+
+	get := apiDefinition.Resources["/users"].Get
+	if get.Description != "# Users\nLists all users.\n" {
+		t.Fatalf("Failed keeping block scalar contents: %q", get.Description)
+	}
+
+	if get.Responses[200].Description != "OK" {
+		t.Fatalf("Failed parsing a RAML fragment: %+v", get)
+	}
+}
+
+// Records the messages logged by the parser
+type recordingLogger struct {
+	debug []string
+	trace []string
+}
+
+func (logger *recordingLogger) Debugf(format string, args ...interface{}) {
+	logger.debug = append(logger.debug, fmt.Sprintf(format, args...))
+}
+
+func (logger *recordingLogger) Tracef(format string, args ...interface{}) {
+	logger.trace = append(logger.trace, fmt.Sprintf(format, args...))
+}
+
+func TestLogger(t *testing.T) {
+
+	logger := new(recordingLogger)
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Logged API\n" +
+			"documentation:\n" +
+			"  - title: Overview\n" +
+			"    content: !include overview.md\n")},
+		"overview.md": &fstest.MapFile{Data: []byte("Overview")},
+	}
+
+	_, err := ParseFileWithOptions("api.raml",
+		&ParserOptions{FS: fsys, Logger: logger})
+	if err != nil {
+		t.Fatalf("Failed parsing with a logger:\n  %s", err.Error())
+	}
+
+	if len(logger.debug) != 1 ||
+		!strings.Contains(logger.debug[0], "overview.md") {
+		t.Fatalf("Failed logging the included file: %q", logger.debug)
+	}
+
+	if len(logger.trace) != 1 ||
+		!strings.Contains(logger.trace[0], "content: Overview") {
+		t.Fatalf("Failed logging the preprocessed document: %q", logger.trace)
+	}
+}
+
+func TestSchemaDrift(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Schema Drift API\n"+
+		"schemas:\n"+
+		"  - user: |\n"+
+		"      { \"type\": \"object\", \"properties\": {\n"+
+		"        \"id\": { \"readOnly\": true },\n"+
+		"        \"name\": {},\n"+
+		"        \"address\": { \"properties\": { \"city\": {} } } } }\n"+
+		"/users:\n"+
+		"  post:\n"+
+		"    body:\n"+
+		"      application/json:\n"+
+		"        schema: |\n"+
+		"          { \"type\": \"object\", \"properties\": {\n"+
+		"            \"name\": {}, \"nickname\": {},\n"+
+		"            \"password\": { \"writeOnly\": true },\n"+
+		"            \"address\": { \"properties\": { \"zip\": {} } } } }\n"+
+		"  /{userId}:\n"+
+		"    get:\n"+
+		"      responses:\n"+
+		"        200:\n"+
+		"          body:\n"+
+		"            application/json:\n"+
+		"              schema: user\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing schema drift:\n  %s", err.Error())
+	}
+
+	expected := []SchemaDrift{
+		{"/users", "/users/{userId}", "application/json", "/address/city", false},
+		{"/users", "/users/{userId}", "application/json", "/address/zip", true},
+		{"/users", "/users/{userId}", "application/json", "/nickname", true},
+	}
+
+	drifts := apiDefinition.SchemaDrift()
+	if fmt.Sprint(drifts) != fmt.Sprint(expected) {
+		t.Fatalf("Expected schema drift %v, got %v", expected, drifts)
+	}
+}
+
+func TestOperations(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Operations API\n"+
+		"mediaType: application/json\n"+
+		"/users/{userId}:\n"+
+		"  uriParameters:\n"+
+		"    userId:\n"+
+		"      type: integer\n"+
+		"  displayName: User\n"+
+		"  /posts/{postId}:\n"+
+		"    get:\n"+
+		"      queryParameters:\n"+
+		"        page:\n"+
+		"          type: integer\n"+
+		"      responses:\n"+
+		"        404:\n"+
+		"        200:\n"+
+		"  put:\n"+
+		"    headers:\n"+
+		"      If-Match:\n"+
+		"        required: true\n"+
+		"    body:\n"+
+		"      schema: user\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing operations:\n  %s", err.Error())
+	}
+
+	operations := apiDefinition.Operations()
+	if len(operations) != 2 {
+		t.Fatalf("Expected 2 operations, got %+v", operations)
+	}
+
+	serialized, err := json.Marshal(operations)
+	if err != nil {
+		t.Fatalf("Failed serializing operations: %s", err.Error())
+	}
+
+	expected := `[{"method":"put","path":"/users/{userId}",` +
+		`"displayName":"User",` +
+		`"uriParameters":[{"name":"userId","type":"integer","required":true}],` +
+		`"headers":[{"name":"If-Match","type":"string","required":true}],` +
+		`"mediaTypes":["application/json"]},` +
+		`{"method":"get","path":"/users/{userId}/posts/{postId}",` +
+		`"uriParameters":[{"name":"postId","type":"string","required":true},` +
+		`{"name":"userId","type":"integer","required":true}],` +
+		`"queryParameters":[{"name":"page","type":"integer","required":false}],` +
+		`"statusCodes":[200,404]}]`
+	if string(serialized) != expected {
+		t.Fatalf("Unexpected operations:\n  %s", serialized)
+	}
+}
+
+func TestSourceMaps(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Mapped API\n" +
+			"documentation:\n" +
+			"  - title: Overview\n" +
+			"    content: !include overview.md\n" +
+			"/users:\n" +
+			"  get: !include get-users.raml\n" +
+			"  post:\n" +
+			"    queryParameters: [ oops ]\n")},
+		"overview.md": &fstest.MapFile{Data: []byte("# Overview\n\n" +
+			"Lots of text.\n")},
+		"get-users.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"# Lists users\n" +
+			"description: Lists users.\n" +
+			"queryParameters:\n" +
+			"  page: oops\n")},
+	}
+
+	_, err := ParseFS(fsys, "api.raml")
+	if err == nil {
+		t.Fatalf("Expected errors parsing an invalid RAML file")
+	}
+
+	ramlErr, ok := err.(*RamlError)
+	if !ok {
+		t.Fatalf("Expected a RamlError, got %s", err.Error())
+	}
+
+	expected := []ParseError{{
+		File:     "get-users.raml",
+		Line:     5,
+		Column:   3,
+		NodePath: "/resources//users/get/queryParameters/page",
+		Severity: SeverityError,
+	}, {
+		Line:     9,
+		Column:   5,
+		NodePath: "/resources//users/post/queryParameters",
+		Severity: SeverityError,
+	}}
+
+	for i := range ramlErr.Errors {
+		ramlErr.Errors[i].Message = ""
+	}
+
+	if fmt.Sprintf("%#v", ramlErr.Errors) != fmt.Sprintf("%#v", expected) {
+		t.Fatalf("Failed mapping errors to their files: %q", ramlErr.Errors)
+	}
+}
+
+func TestIncludeTags(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Tagged API\n" +
+			"# Was: !include old-overview.md\n" +
+			"documentation:\n" +
+			"  - title: Overview\n" +
+			"    content: !include overview.md # the overview\n" +
+			"  - title: Includes\n" +
+			"    content: |\n" +
+			"      Use !include to split large documents:\n" +
+			"        schema: !include schema.json\n" +
+			"  - title: Quoted\n" +
+			"    content: \"Write !include file.md\"\n" +
+			"  - !include extra.raml\n")},
+		"overview.md": &fstest.MapFile{Data: []byte("Overview")},
+		"extra.raml": &fstest.MapFile{Data: []byte("title: Extra\n" +
+			"content: More\n")},
+	}
+
+	apiDefinition, err := ParseFS(fsys, "api.raml")
+	if err != nil {
+		t.Fatalf("Failed parsing include tags:\n  %s", err.Error())
+	}
+
+	documentation := apiDefinition.Documentation
+	if len(documentation) != 4 {
+		t.Fatalf("Expected 4 documentation entries, got %+v", documentation)
+	}
+
+	if documentation[0].Content != "Overview" {
+		t.Fatalf("Failed including a tagged value: %q",
+			documentation[0].Content)
+	}
+
+	if documentation[1].Content != "Use !include to split large "+
+		"documents:\n  schema: !include schema.json\n" {
+		t.Fatalf("Failed keeping a block scalar: %q", documentation[1].Content)
+	}
+
+	if documentation[2].Content != "Write !include file.md" {
+		t.Fatalf("Failed keeping a quoted string: %q", documentation[2].Content)
+	}
+
+	if documentation[3].Title != "Extra" || documentation[3].Content != "More" {
+		t.Fatalf("Failed including a sequence entry: %+v", documentation[3])
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Broken API\n" +
+			"traits:\n" +
+			"  - paged:\n" +
+			"      queryParameters:\n" +
+			"        <<parameter>>:\n" +
+			"documentation:\n" +
+			"  - title: Overview\n" +
+			"    content: !include missing.md\n" +
+			"/users:\n" +
+			"  is: [ paged ]\n" +
+			"  get:\n" +
+			"    queryParameters: [ oops ]\n" +
+			"  post: !include missing.raml\n")},
+	}
+
+	_, err := ParseFS(fsys, "api.raml")
+	ramlErr, ok := err.(*RamlError)
+	if !ok {
+		t.Fatalf("Expected a RamlError, got %v", err)
+	}
+
+	if len(ramlErr.Errors) != 4 ||
+		!strings.HasPrefix(ramlErr.Errors[0].Error(), "line 9: Error including file missing.md") ||
+		!strings.HasPrefix(ramlErr.Errors[1].Error(), "line 14: Error including file missing.raml") ||
+		!strings.HasPrefix(ramlErr.Errors[2].Error(), "line 13:") ||
+		!strings.Contains(ramlErr.Errors[3].Error(), "requires parameter parameter") {
+		t.Fatalf("Failed collecting all errors: %q", ramlErr.Errors)
+	}
+
+	_, err = ParseFileWithOptions("api.raml",
+		&ParserOptions{FS: fsys, MaxErrors: 1})
+	ramlErr, ok = err.(*RamlError)
+	if !ok {
+		t.Fatalf("Expected a RamlError, got %v", err)
+	}
+
+	if len(ramlErr.Errors) != 2 ||
+		ramlErr.Errors[0].Column != 14 ||
+		ramlErr.Errors[0].NodePath != "/documentation/content" ||
+		!strings.HasPrefix(ramlErr.Errors[1].Message, "Too many errors") {
+		t.Fatalf("Failed stopping after too many errors: %q", ramlErr.Errors)
+	}
+
+	// Files aren't even read once there are too many problems
+	logger := new(recordingLogger)
+	fsys["api.raml"] = &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+		"title: Broken API\n" +
+		"documentation:\n" +
+		"  - title: First\n" +
+		"    content: !include first.md\n" +
+		"  - title: Second\n" +
+		"    content: !include second.md\n" +
+		"  - title: Third\n" +
+		"    content: !include third.md\n")}
+
+	_, err = ParseFileWithOptions("api.raml",
+		&ParserOptions{FS: fsys, MaxErrors: 1, Logger: logger})
+	ramlErr, ok = err.(*RamlError)
+	if !ok || len(ramlErr.Errors) != 2 {
+		t.Fatalf("Failed stopping after too many errors: %v", err)
+	}
+
+	if len(logger.debug) != 2 ||
+		!strings.Contains(logger.debug[1], "second.md") {
+		t.Fatalf("Failed to stop including files: %q", logger.debug)
+	}
+}
+
+func TestValidateBaseUri(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Versioned API\n"+
+		"baseUri: ftp://{region}.example.com/{version}/api\n"+
+		"protocols: [ HTTP, SPDY ]\n"+
+		"/api/users:\n"+
+		"  get:\n"+
+		"    protocols: [ HTTPS ]\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing base URI:\n  %s", err.Error())
+	}
+
+	expected := []string{
+		"line 4: protocol SPDY is not supported, must be HTTP or HTTPS",
+		"line 3: baseUri ftp://{region}.example.com/{version}/api uses " +
+			"{version}, but no version is declared",
+		"line 3: baseUri ftp://{region}.example.com/{version}/api has " +
+			"unsupported scheme ftp, must be http or https",
+		"line 5: resource /api/users repeats the segment api of baseUri " +
+			"ftp://{region}.example.com/{version}/api",
+	}
+
+	if problems := apiDefinition.Validate().Errors(); fmt.Sprint(problems) !=
+		fmt.Sprint(expected) {
+		t.Fatalf("Unexpected base URI problems: %q", problems)
+	}
+
+	apiDefinition, err = ParseString("#%RAML 0.8\n"+
+		"title: Relative API\n"+
+		"baseUri: /api\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing base URI:\n  %s", err.Error())
+	}
+
+	if problems := apiDefinition.Validate().Errors(); len(problems) != 1 ||
+		problems[0].Message != "baseUri /api is not an absolute URI" {
+		t.Fatalf("Failed detecting a relative base URI: %q", problems)
+	}
+
+	fileName := "./samples/github/github-api-v3.raml"
+	apiDefinition, err = ParseFile(fileName)
+	if err != nil {
+		t.Fatalf("Failed parsing file %s:\n  %s", fileName, err.Error())
+	}
+
+	if problems := apiDefinition.Validate().Errors(); len(problems) > 0 {
+		t.Fatalf("Unexpected problems in %s: %q", fileName, problems)
+	}
+}
+
+func TestResolvedBaseUri(t *testing.T) {
+
+	apiDefinition := &APIDefinition{
+		BaseUri: "https://{host}.example.com/{version}",
+		Version: "v1",
+	}
+
+	baseUri, err := apiDefinition.ResolvedBaseUri()
+	if err != nil || baseUri != "https://{host}.example.com/v1" {
+		t.Fatalf("Failed substituting the version in the base URI: %q, %v",
+			baseUri, err)
+	}
+
+	apiDefinition.Version = ""
+	if _, err = apiDefinition.ResolvedBaseUri(); err == nil {
+		t.Fatalf("Failed detecting a base URI without a version")
+	}
+
+	apiDefinition.BaseUri = "https://api.example.com"
+	if baseUri, err = apiDefinition.ResolvedBaseUri(); err != nil ||
+		baseUri != "https://api.example.com" {
+		t.Fatalf("Failed keeping a base URI without a version: %q, %v",
+			baseUri, err)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+
+	contents := "#%RAML 0.8\n" +
+		"title: Sloppy API\n" +
+		"mediaType: json\n" +
+		"/users:\n" +
+		"  displayName: Users\n" +
+		"  get:\n" +
+		"    description: Lists users.\n" +
+		"    responses:\n" +
+		"      200:\n" +
+		"        body:\n" +
+		"          application/vnd.users+json:\n" +
+		"          users/json:\n" +
+		"  post:\n" +
+		"    responses:\n" +
+		"      201:\n"
+
+	apiDefinition, err := ParseString(contents, ".")
+	if err != nil {
+		t.Fatalf("Failed parsing with warnings:\n  %s", err.Error())
+	}
+
+	expected := []string{
+		"line 3: media type json is not a standard media type",
+		"line 12: media type users/json is not a standard media type",
+		"line 13: method post of resource /users has no description",
+	}
+
+	if fmt.Sprint(apiDefinition.Warnings) != fmt.Sprint(expected) ||
+		apiDefinition.Warnings[0].Severity != SeverityWarning {
+		t.Fatalf("Unexpected warnings: %q", apiDefinition.Warnings)
+	}
+
+	_, err = ParseFileWithOptions("api.raml", &ParserOptions{
+		FS: fstest.MapFS{
+			"api.raml": &fstest.MapFile{Data: []byte(contents)},
+		},
+		WarningsAsErrors: true,
+	})
+
+	ramlErr, ok := err.(*RamlError)
+	if !ok || fmt.Sprint(ramlErr.Errors) != fmt.Sprint(expected) {
+		t.Fatalf("Failed treating warnings as errors: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Invalid API\n"+
+		"baseUri: https://api.example.com\n"+
+		"baseUriParameters:\n"+
+		"  region:\n"+
+		"schemas:\n"+
+		"  - user: '{ \"type\": \"object\" }'\n"+
+		"traits:\n"+
+		"  - paged:\n"+
+		"      description: Paged\n"+
+		"/users:\n"+
+		"  type: collection\n"+
+		"  is: [ paged, sorted ]\n"+
+		"  post:\n"+
+		"    securedBy: [ null, oauth ]\n"+
+		"    queryParameters:\n"+
+		"      avatar:\n"+
+		"        type: file\n"+
+		"      age:\n"+
+		"        type: int\n"+
+		"      name:\n"+
+		"        minimum: 1\n"+
+		"    body:\n"+
+		"      application/json:\n"+
+		"        schema: usr\n"+
+		"    responses:\n"+
+		"      201:\n"+
+		"        body:\n"+
+		"          application/json:\n"+
+		"            schema: user\n"+
+		"      700:\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing an invalid API:\n  %s", err.Error())
+	}
+
+	expected := []string{
+		"line 5: base URI parameter region is not used in baseUri " +
+			"https://api.example.com",
+		"line 19: type int is not a valid named parameter type",
+		"line 17: type file is only allowed for form parameters",
+		"line 21: minimum and maximum only apply to number and integer " +
+			"parameters",
+		"line 12: resource type collection is not declared",
+		"line 13: trait sorted is not declared",
+		"line 15: security scheme oauth is not declared",
+		"line 25: schema usr is not declared",
+		"line 31: 700 is not a valid HTTP status code",
+	}
+
+	if problems := Validate(apiDefinition).Errors(); fmt.Sprint(problems) !=
+		fmt.Sprint(expected) {
+		t.Fatalf("Unexpected problems:\n  %q", problems)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+
+	directory, err := ioutil.TempDir("", "raml")
+	if err != nil {
+		t.Fatalf("Failed creating a temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(directory)
+
+	configFile := filepath.Join(directory, ConfigFileName)
+	contents := "specs: [ api/api.raml, /specs/other.raml ]\n" +
+		"includeRoot: api\n" +
+		"maxErrors: 20\n" +
+		"warningsAsErrors: true\n" +
+		"mock:\n" +
+		"  port: 8080\n"
+	if err = ioutil.WriteFile(configFile, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed writing %s: %s", configFile, err.Error())
+	}
+
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("Failed loading %s:\n  %s", configFile, err.Error())
+	}
+
+	if len(config.Specs) != 2 ||
+		config.Specs[0] != filepath.Join(directory, "api", "api.raml") ||
+		config.Specs[1] != "/specs/other.raml" {
+		t.Fatalf("Failed resolving spec paths: %q", config.Specs)
+	}
+
+	options := config.ParserOptions()
+	if options.IncludeRoot != filepath.Join(directory, "api") ||
+		options.MaxErrors != 20 || !options.WarningsAsErrors ||
+		options.DisableIncludes {
+		t.Fatalf("Unexpected parser options: %+v", options)
+	}
+
+	if _, err = LoadConfig(filepath.Join(directory, "missing.yaml")); err == nil {
+		t.Fatalf("Expected an error loading a missing config file")
+	}
+}
+
+func TestValidationReport(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Reported API\n"+
+		"/users:\n"+
+		"  displayName: Users\n"+
+		"  is: [ paged ]\n"+
+		"  get:\n"+
+		"    description: Lists users.\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        body:\n"+
+		"          json:\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing a reported API:\n  %s", err.Error())
+	}
+
+	report := apiDefinition.Validate()
+	if report.Valid() || len(report.Errors()) != 1 ||
+		len(report.Warnings()) != 3 {
+		t.Fatalf("Unexpected validation report: %+v", report)
+	}
+
+	serialized, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Failed serializing a validation report: %s", err.Error())
+	}
+
+	expected := `{"issues":[` +
+		`{"line":5,"column":3,"nodePath":"/resources//users/is",` +
+		`"message":"trait paged is not declared","severity":"error",` +
+		`"rule":"undeclared-trait"},` +
+		`{"line":11,"column":11,` +
+		`"nodePath":"/resources//users/get/responses/200/body/json",` +
+		`"message":"media type json is not a standard media type",` +
+		`"severity":"warning","rule":"non-standard-media-type"},` +
+		`{"line":9,"column":7,` +
+		`"nodePath":"/resources//users/get/responses/200",` +
+		`"message":"response 200 of method get of resource /users ` +
+		`declares no Cache-Control header; declare how clients and ` +
+		`proxies may cache it","severity":"warning",` +
+		`"rule":"undeclared-cache-policy"},` +
+		`{"line":6,"column":3,"nodePath":"/resources//users/get",` +
+		`"message":"method get of resource /users is not secured by any ` +
+		`security scheme; apply securedBy to it, its resource or the API, ` +
+		`or securedBy: [ null ] if it is public on purpose",` +
+		`"severity":"warning","rule":"unsecured-method"}]}`
+	if string(serialized) != expected {
+		t.Fatalf("Unexpected serialized report:\n  %s", serialized)
+	}
+
+	report = Validate(new(APIDefinition))
+	if serialized, _ = json.Marshal(report); string(serialized) !=
+		`{"issues":[{"message":"title is required","severity":"error",`+
+			`"rule":"required-title"}]}` {
+		t.Fatalf("Unexpected serialized report:\n  %s", serialized)
+	}
+}
+
+func TestCopy(t *testing.T) {
+
+	fileName := "./samples/congo/api.raml"
+	apiDefinition, err := ParseFile(fileName)
+	if err != nil {
+		t.Fatalf("Failed parsing file %s:\n  %s", fileName, err.Error())
+	}
+
+	copied := apiDefinition.Copy()
+	if !reflect.DeepEqual(apiDefinition, copied) {
+		t.Fatalf("Copy of %s differs from the original", fileName)
+	}
+
+	// Change every resource of the copy
+	for uri, resource := range copied.Resources {
+		resource.Description = "Changed"
+		for _, nested := range resource.Nested {
+			nested.Description = "Changed"
+		}
+		copied.Resources[uri] = resource
+	}
+
+	for uri, resource := range apiDefinition.Resources {
+		if resource.Description == "Changed" {
+			t.Fatalf("Changing the copy changed resource %s", uri)
+		}
+		for nestedUri, nested := range resource.Nested {
+			if nested.Description == "Changed" {
+				t.Fatalf("Changing the copy changed resource %s%s", uri,
+					nestedUri)
+			}
+		}
+	}
+}
+
+func TestConcurrentReaders(t *testing.T) {
+
+	apiDefinition, err := ParseFile("./samples/raml-tutorial-200/jukebox-api.raml")
+	if err != nil {
+		t.Fatalf("Failed parsing file for concurrent readers:\n  %s",
+			err.Error())
+	}
+
+	// Run with -race to catch readers modifying the shared definition
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			apiDefinition.Validate()
+		}()
+		go func() {
+			defer wg.Done()
+			apiDefinition.ResourceGroups()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTraitMethods(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Selective API\n"+
+		"traits:\n"+
+		"  - secured:\n"+
+		"      description: Secured\n"+
+		"  - paged:\n"+
+		"      queryParameters:\n"+
+		"        <<pageParameter>>:\n"+
+		"  - logged:\n"+
+		"      description: Logged\n"+
+		"/users:\n"+
+		"  is:\n"+
+		"    - secured: { (except): [ GET ] }\n"+
+		"    - paged: { (only): get, pageParameter: page }\n"+
+		"    - logged\n"+
+		"  get:\n"+
+		"  post:\n"+
+		"    is: [ logged: { (only): [ fetch ] } ]\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing trait methods:\n  %s", err.Error())
+	}
+
+	resource := apiDefinition.Resources["/users"]
+	var traits []string
+	for _, trait := range resource.MethodTraits("get") {
+		traits = append(traits, trait.Name)
+	}
+	for _, trait := range resource.MethodTraits("post") {
+		traits = append(traits, trait.Name)
+	}
+
+	if fmt.Sprint(traits) != "[paged logged secured logged logged]" {
+		t.Fatalf("Unexpected method traits: %s", traits)
+	}
+
+	if paged := resource.Is[1]; paged.Parameters["pageParameter"] != "page" ||
+		len(paged.Parameters) != 1 {
+		t.Fatalf("Failed keeping trait parameters: %+v", paged)
+	}
+
+	expected := []string{
+		"line 18: trait logged is applied to a method, so it can't be " +
+			"given (only) or (except)",
+	}
+	if problems := apiDefinition.Validate().Errors(); fmt.Sprint(problems) !=
+		fmt.Sprint(expected) {
+		t.Fatalf("Unexpected problems: %q", problems)
+	}
+
+	marshaled, err := yaml.Marshal(resource.Is)
+	if err != nil {
+		t.Fatalf("Failed marshaling trait applications: %s", err.Error())
+	}
+
+	var unmarshaled []DefinitionChoice
+	if err = yaml.Unmarshal(marshaled, &unmarshaled); err != nil ||
+		!reflect.DeepEqual(unmarshaled, resource.Is) {
+		t.Fatalf("Failed preserving trait applications:\n%s", marshaled)
+	}
+}
+
+func TestHeaderNames(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Headers API\n"+
+		"traits:\n"+
+		"  - tracked:\n"+
+		"      headers:\n"+
+		"        x-request-id:\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    headers:\n"+
+		"      if-none-match:\n"+
+		"        description: An ETag\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        headers:\n"+
+		"          ETAG:\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing headers:\n  %s", err.Error())
+	}
+
+	get := apiDefinition.Resources["/users"].Get
+	if _, ok := get.Headers["If-None-Match"]; !ok {
+		t.Fatalf("Failed canonicalizing header names: %+v", get.Headers)
+	}
+
+	if header, ok := get.Header("IF-NONE-MATCH"); !ok ||
+		header.Description != "An ETag" {
+		t.Fatalf("Failed looking up a header ignoring case")
+	}
+
+	response := get.Responses[200]
+	if _, ok := response.Header("etag"); !ok {
+		t.Fatalf("Failed looking up a response header ignoring case")
+	}
+
+	if _, ok := apiDefinition.Traits[0]["tracked"].Headers["X-Request-Id"]; !ok {
+		t.Fatalf("Failed canonicalizing trait header names")
+	}
+
+	_, err = ParseString("#%RAML 0.8\n"+
+		"title: Duplicate headers API\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    headers:\n"+
+		"      Accept:\n"+
+		"      accept:\n", ".")
+
+	ramlErr, ok := err.(*RamlError)
+	if !ok || len(ramlErr.Errors) != 1 || ramlErr.Errors[0].Error() !=
+		"line 7: header accept is also declared as Accept" {
+		t.Fatalf("Failed detecting duplicate headers: %v", err)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+
+	_, err := ParseString("#%RAML 0.8\n"+
+		"title: Duplicates API\n"+
+		"documentation:\n"+
+		"  - title: One\n"+
+		"    content: First\n"+
+		"  - title: Two\n"+
+		"    content: Second\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    headers:\n"+
+		"      Accept:\n"+
+		"        example: '{\n"+
+		"          \"id\": 1,\n"+
+		"          \"id\": 2\n"+
+		"        }'\n"+
+		"      Accept:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        description: Found\n"+
+		"      200:\n"+
+		"        description: Also found\n"+
+		"/users:\n"+
+		"  post:\n"+
+		"    description: Create\n", ".")
+
+	ramlErr, ok := err.(*RamlError)
+	if !ok {
+		t.Fatalf("Failed detecting duplicate keys: %v", err)
+	}
+
+	expected := []string{
+		"line 16: Accept is declared more than once, first on line 11",
+		"line 20: 200 is declared more than once, first on line 18",
+		"line 22: /users is declared more than once, first on line 8",
+	}
+	if len(ramlErr.Errors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), err)
+	}
+	for i, message := range expected {
+		if ramlErr.Errors[i].Error() != message ||
+			ramlErr.Errors[i].Rule != "duplicate-key" {
+			t.Fatalf("Expected %q, got %q", message, ramlErr.Errors[i].Error())
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+
+	contents := []byte("#%RAML 0.8\n" +
+		"title: Partially Broken API\n" +
+		"/users:\n" +
+		"  get:\n" +
+		"    description: List users\n" +
+		"    queryParameters: [ oops ]\n" +
+		"/songs:\n" +
+		"  displayName: Songs\n")
+	fsys := fstest.MapFS{"api.raml": &fstest.MapFile{Data: contents}}
+
+	apiDefinition, err := ParseFS(fsys, "api.raml")
+	if apiDefinition != nil || err == nil {
+		t.Fatalf("Failed detecting a broken definition")
+	}
+
+	apiDefinition, err = ParseFileWithOptions("api.raml",
+		&ParserOptions{FS: fsys, Lenient: true})
+	if _, ok := err.(*RamlError); !ok {
+		t.Fatalf("Expected a RamlError, got %v", err)
+	}
+
+	if apiDefinition == nil || apiDefinition.Title != "Partially Broken API" {
+		t.Fatalf("Failed returning the partial definition: %+v", apiDefinition)
+	}
+
+	if apiDefinition.Resources["/users"].Get.Description != "List users" ||
+		apiDefinition.Resources["/songs"].DisplayName != "Songs" {
+		t.Fatalf("Failed decoding the valid parts of the definition: %+v",
+			apiDefinition.Resources)
+	}
+}
+
+func TestNames(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Names API\n"+
+		"securitySchemes:\n"+
+		"  - oauth_2_0:\n"+
+		"      type: OAuth 2.0\n"+
+		"traits:\n"+
+		"  - paged:\n"+
+		"      queryParameters:\n"+
+		"        page:\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      get?:\n"+
+		"        description: List\n"+
+		"/users:\n"+
+		"  type: collection\n"+
+		"  uriParameters:\n"+
+		"    version:\n"+
+		"  get:\n"+
+		"    headers:\n"+
+		"      x-request-id:\n"+
+		"    responses:\n"+
+		"      404:\n"+
+		"        description: Not found\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing names:\n  %s", err.Error())
+	}
+
+	if name := apiDefinition.SecuritySchemes[0]["oauth_2_0"].Name; name != "oauth_2_0" {
+		t.Fatalf("Failed naming a security scheme: %q", name)
+	}
+
+	trait := apiDefinition.Traits[0]["paged"]
+	if trait.Name != "paged" || trait.QueryParameters["page"].Name != "page" {
+		t.Fatalf("Failed naming a trait and its parameters: %+v", trait)
+	}
+
+	resourceType := apiDefinition.ResourceTypes[0]["collection"]
+	if resourceType.Name != "collection" || resourceType.OptionalGet.Name != "get" {
+		t.Fatalf("Failed naming a resource type and its methods: %+v",
+			resourceType)
+	}
+
+	resource := apiDefinition.Resources["/users"]
+	if resource.UriParameters["version"].Name != "version" {
+		t.Fatalf("Failed naming a URI parameter: %+v", resource.UriParameters)
+	}
+
+	get := resource.Get
+	if get.Name != "get" || get.Headers["X-Request-Id"].Name != "X-Request-Id" ||
+		get.Responses[404].HTTPCode != 404 {
+		t.Fatalf("Failed naming a method and its nodes: %+v", get)
+	}
+}
+
+func TestImplicitUriParameters(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Implicit URI Parameters API\n"+
+		"resourceTypes:\n"+
+		"  - member:\n"+
+		"      uriParameters:\n"+
+		"        fileId:\n"+
+		"          type: integer\n"+
+		"/folders/{folderId}:\n"+
+		"  /files/{fileId}{mediaTypeExtension}:\n"+
+		"    type: member\n"+
+		"    uriParameters:\n"+
+		"      mediaTypeExtension:\n"+
+		"        enum: [ .json ]\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing implicit URI parameters:\n  %s", err.Error())
+	}
+
+	folder := apiDefinition.Resources["/folders/{folderId}"]
+	expected := NamedParameter{Name: "folderId", DisplayName: "folderId",
+		Type: "string", Required: true}
+	if !reflect.DeepEqual(folder.UriParameters["folderId"], expected) {
+		t.Fatalf("Failed declaring an implicit URI parameter: %+v",
+			folder.UriParameters)
+	}
+
+	files := folder.Nested["/files/{fileId}{mediaTypeExtension}"]
+	if len(files.UriParameters) != 1 ||
+		files.UriParameters["mediaTypeExtension"].Required {
+		t.Fatalf("Failed keeping declared URI parameters: %+v",
+			files.UriParameters)
+	}
+
+	if files.Parent.UriParameters["folderId"].Name != "folderId" {
+		t.Fatalf("Failed linking a resource with implicit URI parameters")
+	}
+
+	resolved, err := apiDefinition.Resolve()
+	if err != nil {
+		t.Fatalf("Failed resolving implicit URI parameters: %s", err.Error())
+	}
+
+	resolvedFiles := resolved.Resources["/folders/{folderId}"].
+		Nested["/files/{fileId}{mediaTypeExtension}"]
+	if resolvedFiles.UriParameters["fileId"].Type != "integer" {
+		t.Fatalf("Failed inheriting a URI parameter: %+v",
+			resolvedFiles.UriParameters)
+	}
+
+	// Optional URI parameters of resource types aren't declared implicitly
+	apiDefinition, err = ParseString("#%RAML 0.8\n"+
+		"title: Optional URI Parameters API\n"+
+		"resourceTypes:\n"+
+		"  - part:\n"+
+		"      uriParameters?:\n"+
+		"        itemId:\n"+
+		"          type: integer\n"+
+		"/items/{itemId}/{partId}:\n"+
+		"  type: part\n"+
+		"  uriParameters:\n"+
+		"    partId:\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing optional URI parameters:\n  %s", err.Error())
+	}
+
+	parts := apiDefinition.Resources["/items/{itemId}/{partId}"]
+	if _, ok := parts.UriParameters["itemId"]; ok {
+		t.Fatalf("Failed leaving out an optional URI parameter: %+v",
+			parts.UriParameters)
+	}
+
+	if resolved, err = apiDefinition.Resolve(); err != nil ||
+		resolved.Resources["/items/{itemId}/{partId}"].
+			UriParameters["itemId"].Type != "integer" {
+		t.Fatalf("Failed inheriting an optional URI parameter: %v", err)
+	}
+
+	apiDefinition, err = ParseString("#%RAML 0.8\n"+
+		"title: Reserved URI Parameters API\n"+
+		"version: v1\n"+
+		"/{version}/users{mediaTypeExtension}:\n"+
+		"  get:\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing reserved URI parameters:\n  %s", err.Error())
+	}
+
+	users := apiDefinition.Resources["/{version}/users{mediaTypeExtension}"]
+	if len(users.UriParameters) != 0 {
+		t.Fatalf("Declared reserved URI parameters: %+v", users.UriParameters)
+	}
+
+	for _, issue := range apiDefinition.Validate().Issues {
+		if issue.Rule == "reserved-uri-parameter" {
+			t.Fatalf("Reported an implicit reserved URI parameter: %v", issue)
+		}
+	}
+}
+
+func TestResourceParents(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Parents API\n"+
+		"/users:\n"+
+		"  displayName: Users\n"+
+		"  /{userId}:\n"+
+		"    /songs:\n"+
+		"      get:\n"+
+		"        description: List songs\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing nested resources:\n  %s", err.Error())
+	}
+
+	check := func(api *APIDefinition) {
+		users := api.Resources["/users"]
+		songs := users.Nested["/{userId}"].Nested["/songs"]
+
+		if users.URI != "/users" || users.Parent != nil || songs.URI != "/songs" {
+			t.Fatalf("Failed setting resource URIs: %q, %q", users.URI, songs.URI)
+		}
+
+		if songs.Parent != users.Nested["/{userId}"] ||
+			songs.Parent.Parent.DisplayName != "Users" {
+			t.Fatalf("Failed setting resource parents")
+		}
+
+		if songs.FullURI() != "/users/{userId}/songs" {
+			t.Fatalf("Wrong full URI: %s", songs.FullURI())
+		}
+	}
+
+	check(apiDefinition)
+
+	var snapshot bytes.Buffer
+	if err = apiDefinition.Save(&snapshot); err != nil {
+		t.Fatalf("Failed saving snapshot: %s", err.Error())
+	}
+
+	loaded, err := Load(&snapshot)
+	if err != nil {
+		t.Fatalf("Failed loading snapshot: %s", err.Error())
+	}
+
+	check(loaded)
+	check(apiDefinition.Copy())
+}
+
+func TestExtractSchemas(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Schemas API\n"+
+		"schemas:\n"+
+		"  - usersGetResponse200: '{\"type\": \"string\"}'\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        body:\n"+
+		"          application/json:\n"+
+		"            schema: '{\"type\": \"array\"}'\n"+
+		"  /{userId}:\n"+
+		"    put:\n"+
+		"      body:\n"+
+		"        application/json:\n"+
+		"          schema: '{\"type\": \"object\"}'\n"+
+		"        application/xml:\n"+
+		"          schema: <xs:schema/>\n"+
+		"    get:\n"+
+		"      responses:\n"+
+		"        200:\n"+
+		"          body:\n"+
+		"            application/json:\n"+
+		"              schema: '{\"type\": \"object\"}'\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing inline schemas:\n  %s", err.Error())
+	}
+
+	extracted, added := apiDefinition.ExtractSchemas()
+
+	expected := []string{"usersGetResponse2002", "usersUserIdGetResponse200",
+		"usersUserIdPutRequestXml"}
+	if !reflect.DeepEqual(added, expected) {
+		t.Fatalf("Expected schemas %v, got %v", expected, added)
+	}
+
+	users := extracted.Resources["/users"]
+	user := users.Nested["/{userId}"]
+	if users.Get.Responses[200].Bodies.ForMIMEType["application/json"].Schema !=
+		"usersGetResponse2002" ||
+		user.Put.Bodies.ForMIMEType["application/json"].Schema !=
+			"usersUserIdGetResponse200" {
+		t.Fatalf("Failed referring to the extracted schemas")
+	}
+
+	if len(Validate(extracted).Errors()) != 0 {
+		t.Fatalf("Extracted schemas are not declared: %v", extracted.Schemas)
+	}
+
+	original := apiDefinition.Resources["/users"].Get.Responses[200]
+	if len(apiDefinition.Schemas) != 1 ||
+		original.Bodies.ForMIMEType["application/json"].Schema !=
+			`{"type": "array"}` {
+		t.Fatalf("Extracting schemas changed the original definition")
+	}
+}
+
+func TestSharedSchemas(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Schemas API\n"+
+		"/users:\n"+
+		"  post:\n"+
+		"    body:\n"+
+		"      application/json:\n"+
+		"        schema: '{\"type\": \"object\", \"required\": true}'\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        body:\n"+
+		"          application/json:\n"+
+		"            schema: '{\"type\": \"array\"}'\n"+
+		"  /{userId}:\n"+
+		"    put:\n"+
+		"      body:\n"+
+		"        schema: |\n"+
+		"          { \"required\": true,\n"+
+		"            \"type\": \"object\" }\n"+
+		"    get:\n"+
+		"      responses:\n"+
+		"        200:\n"+
+		"          body:\n"+
+		"            application/json:\n"+
+		"              schema: '{\"type\":\"object\",\"required\":true}'\n",
+		".")
+	if err != nil {
+		t.Fatalf("Failed parsing shared schemas:\n  %s", err.Error())
+	}
+
+	expected := []SharedSchema{{
+		Name:   "usersPostRequest",
+		Schema: `{"type": "object", "required": true}`,
+		NodePaths: []string{
+			"/resources//users/post/body/application/json/schema",
+			"/resources//users//{userId}/get/responses/200/body/" +
+				"application/json/schema",
+			"/resources//users//{userId}/put/body/schema",
+		},
+	}}
+	if shared := apiDefinition.SharedSchemas(); !reflect.DeepEqual(shared,
+		expected) {
+		t.Fatalf("Expected shared schemas %v, got %v", expected, shared)
+	}
+
+	if _, added := apiDefinition.ExtractSchemas(); len(added) != 2 {
+		t.Fatalf("Expected the shared schema to be extracted once, got %v",
+			added)
+	}
+}
+
+func TestCheckTerms(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Terms API\n"+
+		"/users:\n"+
+		"  description: Manage the customers\n"+
+		"  get:\n"+
+		"    description: Lists users\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        body:\n"+
+		"          application/json:\n"+
+		"            schema: |\n"+
+		"              { \"type\": \"object\",\n"+
+		"                \"properties\": { \"user_name\": {}, \"age\": {} } }\n",
+		".")
+	if err != nil {
+		t.Fatalf("Failed parsing terms:\n  %s", err.Error())
+	}
+
+	report := apiDefinition.CheckTerms(func(kind TextKind, text string) []string {
+		switch {
+		case kind == TextDescription && strings.Contains(text, "customer"):
+			return []string{"use user instead of customer"}
+		case kind == TextSchemaProperty && strings.Contains(text, "_"):
+			return []string{text + " is not camel case"}
+		}
+		return nil
+	})
+
+	expected := []string{
+		"line 4: use user instead of customer",
+		"line 11: user_name is not camel case",
+	}
+	if len(report.Issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), report.Issues)
+	}
+	for i, message := range expected {
+		issue := report.Issues[i]
+		if issue.Error() != message || issue.Rule != "terminology" ||
+			issue.Severity != SeverityWarning {
+			t.Fatalf("Expected %q, got %+v", message, issue)
+		}
+	}
+}
+
+func TestPruneExamples(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Examples API\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        body:\n"+
+		"          application/json:\n"+
+		"            example: '{\"users\": [1, 2, 3, 4], \"total\": 4}'\n"+
+		"          text/plain:\n"+
+		"            example: Lots and lots and lots of users\n"+
+		"  post:\n"+
+		"    body:\n"+
+		"      application/json:\n"+
+		"        example: '[1, 2]'\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing examples:\n  %s", err.Error())
+	}
+
+	prunedAPI, pruned := apiDefinition.PruneExamples(
+		ExamplePolicy{MaxArrayElements: 2, MaxSize: 25})
+
+	expected := []PrunedExample{
+		{"/resources//users/get/responses/200/body/application/json/example",
+			35, 25, false},
+		{"/resources//users/get/responses/200/body/text/plain/example",
+			31, 0, true},
+	}
+	if !reflect.DeepEqual(pruned, expected) {
+		t.Fatalf("Expected pruned examples %+v, got %+v", expected, pruned)
+	}
+
+	bodies := prunedAPI.Resources["/users"].Get.Responses[200].Bodies
+	if example := bodies.ForMIMEType["application/json"].Example; example !=
+		`{"total":4,"users":[1,2]}` {
+		t.Fatalf("Failed trimming an example: %s", example)
+	}
+
+	if bodies.ForMIMEType["text/plain"].Example != "" {
+		t.Fatalf("Failed removing a large example")
+	}
+
+	original := apiDefinition.Resources["/users"].Get.Responses[200].Bodies
+	if original.ForMIMEType["text/plain"].Example == "" {
+		t.Fatalf("Pruning examples changed the original definition")
+	}
+
+	// Examples with trailing data are left alone, and the rest is encoded
+	// again without escaping HTML
+	for example, expected := range map[string]string{
+		"[1, 2, 3] [4]":                          "[1, 2, 3] [4]",
+		"[1, 2, 3]]":                             "[1, 2, 3]]",
+		`{"html": "<b>&</b>", "ids": [1, 2, 3]}`: `{"html":"<b>&</b>","ids":[1,2]}`,
+		"[\n  1,\n  2,\n  3\n]\n":                "[\n  1,\n  2\n]\n",
+	} {
+		if pruned := pruneArrays(example, 2); pruned != expected {
+			t.Fatalf("Expected %q pruned to %q, got %q", example, expected,
+				pruned)
+		}
+	}
+}
+
+func TestGetSchema(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Schemas API\n"+
+		"schemas:\n"+
+		"  - user: '{\"type\": \"object\"}'\n"+
+		"  - users: '{\"type\": \"array\"}'\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing schemas:\n  %s", err.Error())
+	}
+
+	if schema, ok := apiDefinition.GetSchema("users"); !ok ||
+		schema != `{"type": "array"}` {
+		t.Fatalf("Failed looking up a schema: %q", schema)
+	}
+
+	if _, ok := apiDefinition.GetSchema("songs"); ok {
+		t.Fatalf("Found an undeclared schema")
+	}
+
+	if _, ok := apiDefinition.Copy().GetSchema("user"); !ok {
+		t.Fatalf("Failed looking up a schema of a copy")
+	}
+
+	_, err = ParseString("#%RAML 0.8\n"+
+		"title: Duplicate schemas API\n"+
+		"schemas:\n"+
+		"  - user: '{\"type\": \"object\"}'\n"+
+		"  - user: '{\"type\": \"array\"}'\n", ".")
+
+	ramlErr, ok := err.(*RamlError)
+	if !ok || len(ramlErr.Errors) != 1 || ramlErr.Errors[0].Error() !=
+		"line 4: schema user is declared more than once" {
+		t.Fatalf("Failed detecting duplicate schemas: %v", err)
+	}
+}
+
+func TestGetTrait(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Traits API\n"+
+		"traits:\n"+
+		"  - paged:\n"+
+		"      description: Paged\n"+
+		"  - secured:\n"+
+		"      description: Secured\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing traits:\n  %s", err.Error())
+	}
+
+	if trait, ok := apiDefinition.GetTrait("secured"); !ok ||
+		trait.Name != "secured" || trait.Description != "Secured" {
+		t.Fatalf("Failed looking up a trait: %+v", trait)
+	}
+
+	if _, ok := apiDefinition.GetTrait("cached"); ok {
+		t.Fatalf("Found an undeclared trait")
+	}
+
+	// Traits declared or changed after parsing are looked up too
+	apiDefinition.Traits = append(apiDefinition.Traits,
+		map[string]Trait{"cached": {Name: "cached"}})
+	apiDefinition.Traits[0]["paged"] = Trait{Name: "paged",
+		Description: "Paged by cursor"}
+
+	if _, ok := apiDefinition.GetTrait("cached"); !ok {
+		t.Fatalf("Failed looking up a trait declared after parsing")
+	}
+	if trait, _ := apiDefinition.GetTrait("paged"); trait.Description !=
+		"Paged by cursor" {
+		t.Fatalf("Looked up a stale trait: %+v", trait)
+	}
+
+	_, err = ParseString("#%RAML 0.8\n"+
+		"title: Duplicate traits API\n"+
+		"traits:\n"+
+		"  - paged:\n"+
+		"      description: Paged\n"+
+		"  - paged:\n"+
+		"      description: Paged again\n", ".")
+
+	ramlErr, ok := err.(*RamlError)
+	if !ok || len(ramlErr.Errors) != 1 ||
+		ramlErr.Errors[0].Rule != "duplicate-trait" {
+		t.Fatalf("Failed detecting duplicate traits: %v", err)
+	}
+}
+
+func TestGetResourceType(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Resource Types API\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      description: A collection\n"+
+		"  - member:\n"+
+		"      description: A member\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing resource types:\n  %s", err.Error())
+	}
+
+	if resourceType, ok := apiDefinition.GetResourceType("member"); !ok ||
+		resourceType.Name != "member" ||
+		resourceType.Description != "A member" {
+		t.Fatalf("Failed looking up a resource type: %+v", resourceType)
+	}
+
+	if _, ok := apiDefinition.GetResourceType("readOnly"); ok {
+		t.Fatalf("Found an undeclared resource type")
+	}
+
+	_, err = ParseString("#%RAML 0.8\n"+
+		"title: Duplicate resource types API\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      description: A collection\n"+
+		"  - collection:\n"+
+		"      description: Another collection\n", ".")
+
+	ramlErr, ok := err.(*RamlError)
+	if !ok || len(ramlErr.Errors) != 1 ||
+		ramlErr.Errors[0].Rule != "duplicate-resource-type" {
+		t.Fatalf("Failed detecting duplicate resource types: %v", err)
+	}
+}
+
+func TestResourceTypeMerges(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Merges API\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      get:\n"+
+		"        queryParameters:\n"+
+		"          name:\n"+
+		"            minLength: 5\n"+
+		"          page:\n"+
+		"            type: integer\n"+
+		"            maximum: 10\n"+
+		"      post?:\n"+
+		"        headers:\n"+
+		"          X-Tag:\n"+
+		"            maxLength: 3\n"+
+		"/users:\n"+
+		"  type: collection\n"+
+		"  get:\n"+
+		"    queryParameters:\n"+
+		"      name:\n"+
+		"        maxLength: 3\n"+
+		"      page:\n"+
+		"        type: integer\n"+
+		"        minimum: 1\n"+
+		"  post:\n"+
+		"    headers:\n"+
+		"      X-Tag:\n"+
+		"        minLength: 4\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing resource types:\n  %s", err.Error())
+	}
+
+	var issues []string
+	for _, issue := range apiDefinition.Validate().Issues {
+		if issue.Rule == "resource-type-merge" {
+			issues = append(issues, issue.Error())
+		}
+	}
+
+	expected := []string{
+		"line 20: merging with resource type collection gives minLength 5 " +
+			"greater than maxLength 3",
+		"line 27: merging with resource type collection gives minLength 4 " +
+			"greater than maxLength 3",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected merge issues %q, got %q", expected, issues)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Constraints API\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    queryParameters:\n"+
+		"      page:\n"+
+		"        type: integer\n"+
+		"        minimum: 10\n"+
+		"        maximum: 1\n"+
+		"      sort:\n"+
+		"        enum: [ name, age ]\n"+
+		"        pattern: ^[a-m]+$\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        body:\n"+
+		"          application/json:\n"+
+		"            schema: |\n"+
+		"              { \"type\": \"object\", \"properties\": {\n"+
+		"                \"name\": { \"minLength\": 5, \"maxLength\": 2 },\n"+
+		"                \"role\": { \"enum\": [] } } }\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing constraints:\n  %s", err.Error())
+	}
+
+	var issues []string
+	for _, issue := range apiDefinition.Validate().Issues {
+		if issue.Rule == "unsatisfiable-constraint" {
+			issues = append(issues, issue.Error())
+		}
+	}
+
+	expected := []string{
+		"line 6: minimum 10 greater than maximum 1",
+		"line 10: enum value name doesn't match pattern ^[a-m]+$",
+		"line 17: /properties/name: minLength 5 greater than maxLength 2",
+		"line 17: /properties/role: enum has no values",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected constraint issues %q, got %q", expected, issues)
+	}
+}
+
+func TestExampleSchemas(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Example Schemas API\n"+
+		"schemas:\n"+
+		"  - user: |\n"+
+		"      { \"type\": \"object\", \"required\": [ \"id\", \"name\" ],\n"+
+		"        \"properties\": { \"id\": {}, \"name\": {} } }\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        body:\n"+
+		"          application/json:\n"+
+		"            schema: |\n"+
+		"              { \"type\": \"array\", \"items\": {\n"+
+		"                \"properties\": { \"id\": { \"required\": true } } } }\n"+
+		"            example: |\n"+
+		"              [ { \"id\": 1 }, { \"nickname\": \"al\" } ]\n"+
+		"  post:\n"+
+		"    body:\n"+
+		"      application/json:\n"+
+		"        schema: user\n"+
+		"        example: |\n"+
+		"          { \"name\": \"Al\", \"email\": \"al@example.com\" }\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing example schemas:\n  %s", err.Error())
+	}
+
+	var issues []string
+	for _, issue := range apiDefinition.Validate().Issues {
+		if issue.Rule == "example-schema-mismatch" {
+			issues = append(issues, issue.Error())
+		}
+	}
+
+	expected := []string{
+		"line 16: /1/id is required by the schema but missing from the example",
+		"line 16: /1/nickname is in the example but not declared in the schema",
+		"line 22: /email is in the example but not declared in the schema",
+		"line 22: /id is required by the schema but missing from the example",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected example schema issues %q, got %q", expected, issues)
+	}
+}
+
+func TestReservedParameters(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Reserved Parameters API\n"+
+		"version: v1\n"+
+		"/users{mediaTypeExtension}:\n"+
+		"  uriParameters:\n"+
+		"    mediaTypeExtension:\n"+
+		"      enum: [ .json, xml ]\n"+
+		"    version:\n"+
+		"      type: string\n"+
+		"  /{userId}{mediaTypeExtension}:\n"+
+		"    uriParameters:\n"+
+		"      mediaTypeExtension:\n"+
+		"        type: integer\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing reserved parameters:\n  %s", err.Error())
+	}
+
+	var issues []string
+	for _, issue := range apiDefinition.Validate().Issues {
+		if issue.Rule == "reserved-uri-parameter" {
+			issues = append(issues, issue.Error())
+		}
+	}
+
+	expected := []string{
+		"line 8: version is reserved and can't be declared as a URI " +
+			"parameter, its value is set by the version property",
+		"line 6: mediaTypeExtension is reserved and can only take media " +
+			"type extensions starting with a dot, e.g. .json, not xml",
+		"line 12: mediaTypeExtension is reserved and must be a string, " +
+			"not of type integer",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected reserved parameter issues %q, got %q", expected,
+			issues)
+	}
+}
+
+func TestGetSecurityScheme(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Security API\n"+
+		"securitySchemes:\n"+
+		"  - oauth_2_0:\n"+
+		"      type: OAuth 2.0\n"+
+		"  - basic:\n"+
+		"      type: Basic Authentication\n"+
+		"securedBy: [ basic ]\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing security schemes:\n  %s", err.Error())
+	}
+
+	securityScheme, ok := apiDefinition.GetSecurityScheme(
+		apiDefinition.SecuredBy[0].Name)
+	if !ok || securityScheme.Name != "basic" ||
+		securityScheme.Type != "Basic Authentication" {
+		t.Fatalf("Failed looking up a security scheme: %+v", securityScheme)
+	}
+
+	if _, ok := apiDefinition.GetSecurityScheme("digest"); ok {
+		t.Fatalf("Found an undeclared security scheme")
+	}
+
+	_, err = ParseString("#%RAML 0.8\n"+
+		"title: Duplicate security schemes API\n"+
+		"securitySchemes:\n"+
+		"  - basic:\n"+
+		"      type: Basic Authentication\n"+
+		"  - basic:\n"+
+		"      type: Digest Authentication\n", ".")
+
+	ramlErr, ok := err.(*RamlError)
+	if !ok || len(ramlErr.Errors) != 1 ||
+		ramlErr.Errors[0].Rule != "duplicate-security-scheme" {
+		t.Fatalf("Failed detecting duplicate security schemes: %v", err)
+	}
+}
+
+func TestResolveTraits(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Traits API\n"+
+		"traits:\n"+
+		"  - paged:\n"+
+		"      description: A page of <<items>>\n"+
+		"      queryParameters:\n"+
+		"        page:\n"+
+		"          type: integer\n"+
+		"          description: The page\n"+
+		"        <<sizeName>>:\n"+
+		"          type: integer\n"+
+		"  - secured:\n"+
+		"      headers:\n"+
+		"        Authorization:\n"+
+		"          description: A token for <<methodName>> <<resourcePath>>\n"+
+		"      responses:\n"+
+		"        401:\n"+
+		"          description: Unauthorized\n"+
+		"/users:\n"+
+		"  is: [ secured ]\n"+
+		"  get:\n"+
+		"    is: [ paged: { items: users, sizeName: perPage } ]\n"+
+		"    queryParameters:\n"+
+		"      page:\n"+
+		"        description: The page of users\n"+
+		"  post:\n"+
+		"    description: Creates a user\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing traits:\n  %s", err.Error())
+	}
+
+	resolved, err := apiDefinition.Resolve()
+	if err != nil {
+		t.Fatalf("Failed resolving traits: %s", err.Error())
+	}
+
+	get := resolved.Resources["/users"].Get
+	if get.Description != "A page of users" {
+		t.Fatalf("Failed applying a trait description: %q", get.Description)
+	}
+
+	page := get.QueryParameters["page"]
+	if page.Description != "The page of users" || page.Type != "integer" {
+		t.Fatalf("Failed merging a query parameter: %+v", page)
+	}
+
+	if _, ok := get.QueryParameters["perPage"]; !ok {
+		t.Fatalf("Failed substituting a trait parameter: %+v",
+			get.QueryParameters)
+	}
+
+	post := resolved.Resources["/users"].Post
+	if _, ok := post.Headers["Authorization"]; !ok ||
+		post.Responses[401].Description != "Unauthorized" ||
+		post.Description != "Creates a user" {
+		t.Fatalf("Failed applying a resource trait: %+v", post)
+	}
+
+	if description := post.Headers["Authorization"].Description; description !=
+		"A token for post /users" {
+		t.Fatalf("Failed setting the reserved parameters of a trait: %q",
+			description)
+	}
+
+	if len(apiDefinition.Resources["/users"].Post.Headers) != 0 {
+		t.Fatalf("Resolving changed the original definition")
+	}
+
+	apiDefinition.Resources["/users"].Get.Is[0].Name = "cached"
+	if _, err = apiDefinition.Resolve(); err == nil {
+		t.Fatalf("Failed detecting an undeclared trait")
+	}
+
+	// Reserved parameters can't be given, even when not checked
+	apiDefinition, err = ParseStringWithOptions("#%RAML 0.8\n"+
+		"title: Traits API\n"+
+		"traits:\n"+
+		"  - secured:\n"+
+		"      description: <<methodName>> <<resourcePath>>\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    is: [ secured: { methodName: put, resourcePath: /songs } ]\n",
+		".", &ParserOptions{SkipParameterChecks: true})
+	if err != nil {
+		t.Fatalf("Failed parsing traits:\n  %s", err.Error())
+	}
+
+	if resolved, err = apiDefinition.Resolve(); err != nil ||
+		resolved.Resources["/users"].Get.Description != "get /users" {
+		t.Fatalf("Failed overriding given reserved parameters: %v", err)
+	}
+}
+
+func TestResolveResourceTypes(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Resource Types API\n"+
+		"traits:\n"+
+		"  - secured:\n"+
+		"      headers:\n"+
+		"        Authorization:\n"+
+		"          description: A token\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      description: A collection of <<items>>\n"+
+		"      get:\n"+
+		"        description: Lists <<items>>\n"+
+		"        responses:\n"+
+		"          200:\n"+
+		"            description: The <<items>>\n"+
+		"      post:\n"+
+		"        description: Creates one of the <<items>>\n"+
+		"/users:\n"+
+		"  type: { collection: { items: users } }\n"+
+		"  is: [ secured ]\n"+
+		"  post:\n"+
+		"    description: Signs up a user\n"+
+		"  /{userId}:\n"+
+		"    get:\n"+
+		"      description: Gets a user\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing resource types:\n  %s", err.Error())
+	}
+
+	resolved, err := apiDefinition.Resolve()
+	if err != nil {
+		t.Fatalf("Failed resolving resource types: %s", err.Error())
+	}
+
+	users := resolved.Resources["/users"]
+	if users.Description != "A collection of users" {
+		t.Fatalf("Failed applying a resource type description: %q",
+			users.Description)
+	}
+
+	if users.Get == nil || users.Get.Description != "Lists users" ||
+		users.Get.Responses[200].Description != "The users" {
+		t.Fatalf("Failed inheriting a method: %+v", users.Get)
+	}
+
+	if users.Post.Description != "Signs up a user" {
+		t.Fatalf("Failed keeping a method's own description: %q",
+			users.Post.Description)
+	}
+
+	if _, ok := users.Get.Headers["Authorization"]; !ok {
+		t.Fatalf("Failed applying a trait to an inherited method")
+	}
+
+	if users.Nested["/{userId}"].Parent.Get == nil {
+		t.Fatalf("Failed linking nested resources to the resolved resource")
+	}
+
+	if apiDefinition.Resources["/users"].Get != nil {
+		t.Fatalf("Resolving changed the original definition")
+	}
+}
+
+func TestResolveResourcePath(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Resource Path API\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      description: All <<resourcePathName>> at <<resourcePath>>\n"+
+		"      post:\n"+
+		"        description: Adds a <<resourcePathName | !singularize>>\n"+
+		"  - member:\n"+
+		"      get:\n"+
+		"        description: Gets <<resourcePathName>> at <<resourcePath>>\n"+
+		"/users{mediaTypeExtension}:\n"+
+		"  type: collection\n"+
+		"  /{userId}:\n"+
+		"    type: member\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing reserved parameters:\n  %s", err.Error())
+	}
+
+	resolved, err := apiDefinition.Resolve()
+	if err != nil {
+		t.Fatalf("Failed resolving reserved parameters: %s", err.Error())
+	}
+
+	users := resolved.Resources["/users{mediaTypeExtension}"]
+	if users.Description != "All users at /users" {
+		t.Fatalf("Failed setting the reserved parameters of a collection: %q",
+			users.Description)
+	}
+
+	if users.Post == nil || users.Post.Description != "Adds a user" {
+		t.Fatalf("Failed transforming a reserved parameter: %+v", users.Post)
+	}
+
+	user := users.Nested["/{userId}"]
+	if user.Get == nil ||
+		user.Get.Description != "Gets {userId} at /users/{userId}" {
+		t.Fatalf("Failed setting the reserved parameters of a member: %+v",
+			user.Get)
+	}
+}
+
+func TestResolveOptionalProperties(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Optional Properties API\n"+
+		"traits:\n"+
+		"  - validated:\n"+
+		"      body?:\n"+
+		"        application/json:\n"+
+		"          description: A validated body\n"+
+		"      headers?:\n"+
+		"        X-Request-Id:\n"+
+		"          description: An identifier\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      get:\n"+
+		"        description: Lists items\n"+
+		"      get?:\n"+
+		"        queryParameters:\n"+
+		"          page:\n"+
+		"            type: integer\n"+
+		"      post?:\n"+
+		"        description: Adds an item\n"+
+		"      delete?:\n"+
+		"        description: Deletes items\n"+
+		"/users:\n"+
+		"  type: collection\n"+
+		"  is: [ validated ]\n"+
+		"  post:\n"+
+		"    body:\n"+
+		"      application/json:\n"+
+		"        schema: user\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing optional properties:\n  %s", err.Error())
+	}
+
+	resolved, err := apiDefinition.Resolve()
+	if err != nil {
+		t.Fatalf("Failed resolving optional properties: %s", err.Error())
+	}
+
+	users := resolved.Resources["/users"]
+	if users.Delete != nil {
+		t.Fatalf("Failed dropping an optional method: %+v", users.Delete)
+	}
+
+	if users.Post.Description != "Adds an item" {
+		t.Fatalf("Failed applying an optional method: %+v", users.Post)
+	}
+
+	if _, ok := users.Get.QueryParameters["page"]; !ok {
+		t.Fatalf("Failed applying an optional method to an inherited one: %+v",
+			users.Get)
+	}
+
+	body := users.Post.Bodies.ForMIMEType["application/json"]
+	if body.Description != "A validated body" || body.Schema != "user" {
+		t.Fatalf("Failed applying an optional body: %+v", body)
+	}
+
+	if len(users.Get.Bodies.ForMIMEType) != 0 ||
+		len(users.Get.Headers) != 0 || len(users.Post.Headers) != 0 {
+		t.Fatalf("Failed dropping optional properties: %+v", users)
+	}
+}
+
+func TestInflections(t *testing.T) {
+
+	plurals := map[string]string{
+		"user":         "users",
+		"users":        "users",
+		"category":     "categories",
+		"address":      "addresses",
+		"box":          "boxes",
+		"knife":        "knives",
+		"person":       "people",
+		"Child":        "Children",
+		"sheep":        "sheep",
+		"status":       "statuses",
+		"userAccount":  "userAccounts",
+		"user_company": "user_companies",
+	}
+
+	for singular, plural := range plurals {
+		if pluralized := pluralize(singular); pluralized != plural {
+			t.Fatalf("Pluralized %s into %s instead of %s", singular,
+				pluralized, plural)
+		}
+		if singular == "users" {
+			continue
+		}
+		if singularized := singularize(plural); singularized != singular {
+			t.Fatalf("Singularized %s into %s instead of %s", plural,
+				singularized, singular)
+		}
+	}
+
+	substituted, err := substituteParameters(
+		"<<resourcePathName | !singularize>> of <<owner | !pluralize>>",
+		map[string]string{"resourcePathName": "songs", "owner": "person"})
+	if err != nil || substituted != "song of people" {
+		t.Fatalf("Failed applying inflection functions: %q, %v",
+			substituted, err)
+	}
+}
+
+func TestResourceGroups(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Resource Groups API\n"+
+		"schemas:\n"+
+		"  - user: '{ \"type\": \"object\" }'\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      description: A collection\n"+
+		"/users:\n"+
+		"  type: collection\n"+
+		"  post:\n"+
+		"    body:\n"+
+		"      application/json:\n"+
+		"        schema: user\n"+
+		"  /{userId}:\n"+
+		"    get:\n"+
+		"      responses:\n"+
+		"        200:\n"+
+		"          body:\n"+
+		"            application/json:\n"+
+		"              schema: user\n"+
+		"/songs:\n"+
+		"  type: collection\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing resource groups:\n  %s", err.Error())
+	}
+
+	expected := []ResourceGroup{
+		{GroupByPathPrefix, "/users", []string{"/users", "/users/{userId}"}},
+		{GroupByResourceType, "collection", []string{"/songs", "/users"}},
+		{GroupBySchema, "user", []string{"/users", "/users/{userId}"}},
+	}
+
+	if groups := apiDefinition.ResourceGroups(); !reflect.DeepEqual(groups,
+		expected) {
+		t.Fatalf("Expected resource groups %+v, got %+v", expected, groups)
+	}
+}
+
+func TestGetResource(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Matched API\n"+
+		"/users:\n"+
+		"  displayName: Users\n"+
+		"  /me:\n"+
+		"    displayName: Me\n"+
+		"  /{userId}:\n"+
+		"    displayName: User\n"+
+		"    /posts{mediaTypeExtension}:\n"+
+		"      displayName: Posts\n"+
+		"/files/{name}.json:\n"+
+		"  displayName: File\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing resources to match:\n  %s", err.Error())
+	}
+
+	for path, expected := range map[string]string{
+		"/users":              "Users",
+		"/users/":             "Users",
+		"/users/me":           "Me",
+		"/users/42?fields=a":  "User",
+		"/users/42/posts":     "Posts",
+		"/users/42/posts.xml": "Posts",
+		"/files/a.b.json":     "File",
+		"/users/42/likes":     "",
+		"/users//posts":       "",
+		"/files/.json":        "",
+	} {
+		resource := apiDefinition.GetResource(path)
+		if expected == "" && resource != nil ||
+			expected != "" && (resource == nil ||
+				resource.DisplayName != expected) {
+			t.Fatalf("Expected %s to match %q, got %+v", path, expected,
+				resource)
+		}
+	}
+
+	for _, test := range []struct{ method, path, expected string }{
+		{"get", "/users/42", "GET /users/{userId}"},
+		{"POST", "/users/42/posts.json", "POST /users/{userId}/posts{mediaTypeExtension}"},
+		{"get", "/unknown/42", "GET"},
+	} {
+		if name := apiDefinition.SpanName(test.method,
+			test.path); name != test.expected {
+			t.Fatalf("Expected span name %q for %s %s, got %q", test.expected,
+				test.method, test.path, name)
+		}
+	}
+}
+
+func TestDesignRules(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Designed API\n"+
+		"/user:\n"+
+		"  post:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"  get:\n"+
+		"    queryParameters:\n"+
+		"      userId:\n"+
+		"      pageSize:\n"+
+		"      sort_order:\n"+
+		"      authorId:\n"+
+		"  /{userId}:\n"+
+		"    delete:\n"+
+		"      responses:\n"+
+		"        200:\n"+
+		"/getOrders:\n"+
+		"  get:\n"+
+		"/orders:\n"+
+		"  post:\n"+
+		"    responses:\n"+
+		"      201:\n"+
+		"  /{orderId}:\n"+
+		"    delete:\n"+
+		"      responses:\n"+
+		"        204:\n"+
+		"    /ship:\n"+
+		"      post:\n"+
+		"        responses:\n"+
+		"          200:\n"+
+		"/users/{userId}/following/{targetId}:\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing a designed API:\n  %s", err.Error())
+	}
+
+	designRules := map[string]bool{"verb-in-path": true,
+		"singular-collection": true, "id-in-query": true,
+		"create-status": true, "delete-status": true, "parameter-casing": true}
+
+	var issues []string
+	for _, issue := range apiDefinition.Validate().Issues {
+		if designRules[issue.Rule] {
+			if issue.Severity != SeverityWarning {
+				t.Fatalf("Expected design issues to be warnings: %+v", issue)
+			}
+			issues = append(issues, issue.Rule+": "+issue.Error())
+		}
+	}
+
+	expected := []string{
+		"verb-in-path: line 17: resource /getOrders uses the verb get in " +
+			"its path; name resources by nouns and let the HTTP method " +
+			"tell what to do with them",
+		"id-in-query: line 9: query parameter userId of method get of " +
+			"resource /user identifies an item; address items by a URI " +
+			"parameter instead, e.g. /user/{userId}",
+		"create-status: line 5: method post of resource /user responds " +
+			"200, but not 201; creating an item should respond 201 " +
+			"Created, with the location of the new item",
+		"singular-collection: line 13: collection /user is named in the " +
+			"singular; name collections in the plural, e.g. /users, so " +
+			"that /users/{userId} reads as one of them",
+		"delete-status: line 15: method delete of resource /user/{userId} " +
+			"responds 200 without a body; respond 204 No Content instead " +
+			"when there is nothing to return",
+		"parameter-casing: line 11: parameter sort_order is in snake_case, " +
+			"while most parameters are in camelCase; case parameters " +
+			"alike so that clients don't have to guess",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected design issues %q, got %q", expected, issues)
+	}
+}
+
+func TestParseListener(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Listened API\n" +
+			"traits:\n" +
+			"  - paged: !include paged.yaml\n" +
+			"/users:\n" +
+			"  displayName: Users\n" +
+			"  get:\n" +
+			"    is: [ paged ]\n" +
+			"  /{userId}:\n" +
+			"    get:\n")},
+		"paged.yaml": &fstest.MapFile{Data: []byte("queryParameters:\n" +
+			"  page:\n" +
+			"    type: integer\n")},
+	}
+
+	var events []string
+	listener := func(event ParseEvent) {
+		switch event.Kind {
+		case EventFileIncluded:
+			events = append(events, "included "+event.File)
+		case EventResourceParsed:
+			events = append(events, "parsed "+event.ResourcePath)
+		case EventTraitApplied:
+			events = append(events, "applied "+event.Trait+" to "+
+				event.Method+" "+event.ResourcePath)
+		case EventIssueFound:
+			events = append(events, "found "+event.Issue.Error())
+		}
+	}
+
+	apiDefinition, err := ParseFileWithOptions("api.raml",
+		&ParserOptions{FS: fsys, Listener: listener})
+	if err != nil {
+		t.Fatalf("Failed parsing with a listener:\n  %s", err.Error())
+	}
+
+	if _, err = apiDefinition.Resolve(); err != nil {
+		t.Fatalf("Failed resolving with a listener:\n  %s", err.Error())
+	}
+
+	expected := []string{
+		"included paged.yaml",
+		"parsed /users",
+		"parsed /users/{userId}",
+		"found line 7: method get of resource /users has no description",
+		"found line 9: resource /users/{userId} has no displayName",
+		"applied paged to get /users",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("Expected events %q, got %q", expected, events)
+	}
+
+	// Errors are reported as well
+	events = nil
+	_, err = ParseStringWithOptions("#%RAML 0.8\n"+
+		"title: Broken API\n"+
+		"title: Broken API\n", ".", &ParserOptions{Listener: listener})
+	if err == nil || len(events) != 1 ||
+		!strings.HasPrefix(events[0], "found line 3: title is declared") {
+		t.Fatalf("Failed reporting an error to the listener: %q", events)
+	}
+}
+
+func TestExplain(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Explained API\n"+
+		"resourceTypes:\n"+
+		"  - collection:\n"+
+		"      get:\n"+
+		"        description: Lists <<resourcePathName>>\n"+
+		"traits:\n"+
+		"  - paged:\n"+
+		"      description: A page of <<items>>\n"+
+		"  - secured:\n"+
+		"      headers:\n"+
+		"        Authorization:\n"+
+		"/users:\n"+
+		"  type: collection\n"+
+		"  is: [ secured ]\n"+
+		"  get:\n"+
+		"    is: [ paged: { items: users } ]\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing an explained API:\n  %s", err.Error())
+	}
+
+	explanation, err := apiDefinition.Explain("/users", "GET")
+	if err != nil {
+		t.Fatalf("Failed explaining a method: %s", err.Error())
+	}
+
+	expected := "GET /users\n" +
+		"  resource type collection (resourcePath: /users, " +
+		"resourcePathName: users)\n" +
+		"  trait secured, applied by the resource (methodName: get, " +
+		"resourcePath: /users, resourcePathName: users)\n" +
+		"  trait paged, applied by the method (items: users, " +
+		"methodName: get, resourcePath: /users, resourcePathName: users)"
+	if explanation.String() != expected {
+		t.Fatalf("Unexpected explanation:\n%s", explanation)
+	}
+
+	if explanation.Resolved.Description != "Lists users" {
+		t.Fatalf("Failed explaining the resolved method: %+v",
+			explanation.Resolved)
+	}
+
+	if _, err = apiDefinition.Explain("/users", "delete"); err == nil {
+		t.Fatalf("Failed detecting an undefined method")
+	}
+
+	if _, err = apiDefinition.Explain("/songs", "get"); err == nil {
+		t.Fatalf("Failed detecting an undefined resource")
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"api.raml": &fstest.MapFile{Data: []byte("#%RAML 0.8\n" +
+			"title: Checked API\n" +
+			"title: Checked API\n" +
+			"/users:\n" +
+			"  get:\n" +
+			"    description: Lists users.\n" +
+			"    is: [ paged ]\n")},
+	}
+
+	report := CheckFile("api.raml", &ParserOptions{FS: fsys})
+
+	expected := "api.raml: error: Error applying trait paged to GET /users " +
+		"(Error: trait paged is not declared) (resolve)\n" +
+		"api.raml:3:1: error: title is declared more than once, first on " +
+		"line 2 (parse, duplicate-key)\n" +
+		"api.raml:4:1: warning: resource /users has no displayName " +
+		"(parse, missing-display-name)\n" +
+		"api.raml:5:3: warning: method get of resource /users is not " +
+		"secured by any security scheme; apply securedBy to it, its " +
+		"resource or the API, or securedBy: [ null ] if it is public on " +
+		"purpose (validate, unsecured-method)\n" +
+		"api.raml:7:5: error: trait paged is not declared " +
+		"(validate, undeclared-trait)"
+	if report.Text() != expected {
+		t.Fatalf("Unexpected report:\n%s", report.Text())
+	}
+
+	serialized, err := json.Marshal(report.Issues[1])
+	if err != nil || string(serialized) != `{"line":3,"column":1,`+
+		`"nodePath":"/title","message":"title is declared more than once, `+
+		`first on line 2","severity":"error","rule":"duplicate-key",`+
+		`"phase":"parse"}` {
+		t.Fatalf("Unexpected serialized issue: %s", serialized)
+	}
+
+	sarif, err := report.SARIF()
+	if err != nil {
+		t.Fatalf("Failed rendering SARIF: %s", err.Error())
+	}
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Results []struct {
+				RuleID    string
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine int }
+					}
+				}
+			}
+		}
+	}
+	if err = json.Unmarshal(sarif, &log); err != nil || log.Version != "2.1.0" ||
+		len(log.Runs[0].Results) != 5 ||
+		log.Runs[0].Results[4].RuleID != "undeclared-trait" ||
+		log.Runs[0].Results[4].Locations[0].PhysicalLocation.Region.StartLine != 7 ||
+		log.Runs[0].Results[4].Locations[0].PhysicalLocation.ArtifactLocation.URI != "api.raml" {
+		t.Fatalf("Unexpected SARIF report:\n%s", sarif)
+	}
+
+	report = CheckFile("missing.raml", &ParserOptions{FS: fsys})
+	if len(report.Issues) != 1 || report.Issues[0].Phase != PhaseParse {
+		t.Fatalf("Failed reporting an unreadable file: %+v", report)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Fingerprinted API\n"+
+		"/users:\n"+
+		"  displayName: Users\n"+
+		"  get:\n"+
+		"    is: [ paged ]\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing a fingerprinted API:\n  %s", err.Error())
+	}
+
+	var saved bytes.Buffer
+	if err = apiDefinition.Validate().Baseline().Save(&saved); err != nil {
+		t.Fatalf("Failed saving a baseline: %s", err.Error())
+	}
+
+	baseline, err := LoadBaseline(&saved)
+	if err != nil {
+		t.Fatalf("Failed loading a baseline: %s", err.Error())
+	}
+
+	// Moving the known issues to other lines keeps their fingerprints
+	apiDefinition, err = ParseString("#%RAML 0.8\n"+
+		"title: Fingerprinted API\n"+
+		"version: v1\n"+
+		"/users:\n"+
+		"  displayName: Users\n"+
+		"  get:\n"+
+		"    is: [ paged ]\n"+
+		"/songs:\n"+
+		"  displayName: Songs\n"+
+		"  get:\n"+
+		"    description: Lists songs.\n"+
+		"    is: [ cached ]\n"+
+		"    securedBy: [ null ]\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing a fingerprinted API:\n  %s", err.Error())
+	}
+
+	report := apiDefinition.Validate()
+	issues := report.NewIssues(baseline)
+	if len(issues) != 1 || issues[0].Error() !=
+		"line 12: trait cached is not declared" {
+		t.Fatalf("Expected only the new issue, got %q", issues)
+	}
+
+	if fixed := report.FixedIssues(baseline); len(fixed) != 0 {
+		t.Fatalf("Expected no fixed issues, got %q", fixed)
+	}
+
+	if fixed := new(ValidationReport).FixedIssues(baseline); len(fixed) !=
+		len(baseline.Fingerprints) || len(fixed) == 0 {
+		t.Fatalf("Expected every known issue to be fixed, got %q", fixed)
+	}
+
+	// Without a baseline, every issue is new and none is fixed
+	if issues := report.NewIssues(nil); len(issues) != len(report.Issues) {
+		t.Fatalf("Expected every issue to be new, got %q", issues)
+	}
+	if fixed := report.FixedIssues(nil); len(fixed) != 0 {
+		t.Fatalf("Expected no fixed issues without a baseline, got %q", fixed)
+	}
+
+	if _, err = LoadBaseline(bytes.NewBufferString("not a baseline")); err == nil {
+		t.Fatalf("Failed detecting a bad baseline")
+	}
+}
+
+func TestCachePolicies(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Cached API\n"+
+		"traits:\n"+
+		"  - cacheable:\n"+
+		"      responses:\n"+
+		"        200:\n"+
+		"          headers:\n"+
+		"            Cache-Control:\n"+
+		"              example: max-age=3600\n"+
+		"/albums:\n"+
+		"  get:\n"+
+		"    is: [ cacheable ]\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"/users:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        headers:\n"+
+		"          cache-control:\n"+
+		"            example: max-age=60\n"+
+		"          Vary:\n"+
+		"            example: Accept, Accept-Encoding\n"+
+		"/songs:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        headers:\n"+
+		"          Cache-Control:\n"+
+		"            example: no-cache\n"+
+		"      206:\n"+
+		"      404:\n"+
+		"  post:\n"+
+		"    responses:\n"+
+		"      201:\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing a cached API:\n  %s", err.Error())
+	}
+
+	policy := apiDefinition.Resources["/users"].Get.CachePolicy()
+	if policy.CacheControl[200] != "max-age=60" ||
+		!reflect.DeepEqual(policy.Vary[200],
+			[]string{"Accept", "Accept-Encoding"}) {
+		t.Fatalf("Unexpected cache policy: %+v", policy)
+	}
+
+	var issues []string
+	for _, issue := range apiDefinition.Validate().Issues {
+		if issue.Rule == "undeclared-cache-policy" {
+			issues = append(issues, issue.Error())
+		}
+	}
+
+	expected := []string{
+		"line 31: response 206 of method get of resource /songs declares " +
+			"no Cache-Control header; declare how clients and proxies may " +
+			"cache it",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected cache policy issues %q, got %q", expected, issues)
+	}
+}
+
+func TestRangeRequests(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Media API\n"+
+		"traits:\n"+
+		"  - ranged:\n"+
+		"      responses:\n"+
+		"        200:\n"+
+		"          headers:\n"+
+		"            Accept-Ranges:\n"+
+		"              example: bytes\n"+
+		"        206:\n"+
+		"          headers:\n"+
+		"            Content-Range:\n"+
+		"              example: bytes 0-1023/4096\n"+
+		"/files:\n"+
+		"  get:\n"+
+		"    is: [ ranged ]\n"+
+		"/videos:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        headers:\n"+
+		"          accept-ranges:\n"+
+		"            example: bytes\n"+
+		"      206:\n"+
+		"/songs:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"      206:\n"+
+		"        headers:\n"+
+		"          Content-Range:\n"+
+		"/images:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        headers:\n"+
+		"          Accept-Ranges:\n"+
+		"            example: bytes\n"+
+		"/documents:\n"+
+		"  get:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"        headers:\n"+
+		"          Accept-Ranges:\n"+
+		"            example: none\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing an API serving ranges:\n  %s", err.Error())
+	}
+
+	var issues []string
+	for _, issue := range apiDefinition.Validate().Issues {
+		if issue.Rule == "range-requests" {
+			issues = append(issues, issue.Error())
+		}
+	}
+
+	expected := []string{
+		"line 35: response 200 of method get of resource /images accepts " +
+			"ranges, but the method declares no 206 response; declare the " +
+			"partial content it responds with",
+		"line 28: response 200 of method get of resource /songs declares no " +
+			"Accept-Ranges header, though the method responds 206; advertise " +
+			"the ranges clients may request",
+		"line 24: response 206 of method get of resource /videos declares " +
+			"no Content-Range header; declare which range of the content it " +
+			"holds",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected range request issues %q, got %q", expected, issues)
+	}
+}
+
+func TestSecurityChecks(t *testing.T) {
+
+	apiDefinition, err := ParseString("#%RAML 0.8\n"+
+		"title: Secured API\n"+
+		"securitySchemes:\n"+
+		"  - oauth_2_0:\n"+
+		"      type: OAuth 2.0\n"+
+		"      describedBy:\n"+
+		"        responses:\n"+
+		"          401:\n"+
+		"      settings:\n"+
+		"        authorizationUri: https://example.com/authorize\n"+
+		"        accessTokenUri: https://example.com/token\n"+
+		"        authorizationGrants: [ token ]\n"+
+		"  - basic:\n"+
+		"      type: Basic Authentication\n"+
+		"/users:\n"+
+		"  securedBy: [ oauth_2_0 ]\n"+
+		"  get:\n"+
+		"    queryParameters:\n"+
+		"      api_key:\n"+
+		"    responses:\n"+
+		"      200:\n"+
+		"  post:\n"+
+		"    securedBy: [ null, oauth_2_0 ]\n"+
+		"  /{userId}:\n"+
+		"    delete:\n"+
+		"      securedBy: [ basic ]\n"+
+		"      responses:\n"+
+		"        204:\n"+
+		"/songs:\n"+
+		"  get:\n"+
+		"    description: Lists the songs\n"+
+		"  /{songId}:\n"+
+		"    get:\n"+
+		"      securedBy: [ null ]\n", ".")
+	if err != nil {
+		t.Fatalf("Failed parsing a secured API:\n  %s", err.Error())
+	}
+
+	securityRules := map[string]bool{"unsecured-method": true,
+		"unauthenticated-mutation": true, "undeclared-auth-responses": true,
+		"sensitive-query-parameter": true, "implicit-grant-only": true}
+
+	var issues []string
+	for _, issue := range apiDefinition.Validate().Issues {
+		if securityRules[issue.Rule] {
+			issues = append(issues, issue.Rule+": "+issue.Error())
+		}
+	}
+
+	expected := []string{
+		"implicit-grant-only: line 12: security scheme oauth_2_0 only " +
+			"allows the implicit grant, which hands access tokens out in " +
+			"redirect URIs; allow the code grant",
+		"unsecured-method: line 30: method get of resource /songs is not " +
+			"secured by any security scheme; apply securedBy to it, its " +
+			"resource or the API, or securedBy: [ null ] if it is public " +
+			"on purpose",
+		"undeclared-auth-responses: line 20: secured method get of " +
+			"resource /users declares no 403 response; document how failed " +
+			"authentication and authorization are answered",
+		"sensitive-query-parameter: line 19: query parameter api_key of " +
+			"method get of resource /users is named like a secret (apikey); " +
+			"send it in a header or the body, as query strings are logged",
+		"unauthenticated-mutation: line 22: method post of resource /users " +
+			"changes data but may be called without authentication; secure " +
+			"it with a security scheme",
+		"undeclared-auth-responses: line 27: secured method delete of " +
+			"resource /users/{userId} declares no 401 and 403 responses; " +
+			"document how failed authentication and authorization are " +
+			"answered",
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("Expected security issues %q, got %q", expected, issues)
+	}
+}
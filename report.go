@@ -0,0 +1,269 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to reporting the issues found in a
+// RAML file by all phases of checking it at once.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The phases of checking a RAML file, in the order they run
+type Phase string
+
+const (
+	// Reading, preprocessing and decoding the file
+	PhaseParse Phase = "parse"
+
+	// Applying resource types and traits
+	PhaseResolve Phase = "resolve"
+
+	// Running the checks of Validate
+	PhaseValidate Phase = "validate"
+)
+
+// An issue found in a phase of checking a RAML file
+type Issue struct {
+	ParseError
+	Phase Phase `json:"phase"`
+}
+
+// A Report holds the issues found by all phases of checking a RAML file,
+// ordered by file, line and column. Issues found by more than one phase,
+// e.g. warnings reported both when parsing and validating, appear once, as
+// found by the earliest phase.
+type Report struct {
+
+	// The path of the RAML file checked, as given
+	File string `json:"file"`
+
+	Issues []Issue `json:"issues"`
+}
+
+// CheckFile parses, resolves and validates a RAML file, reporting the
+// issues found by every phase. Problems found when parsing don't stop the
+// other phases, as the file is parsed leniently, unless they keep the
+// definition from being decoded at all.
+func CheckFile(filePath string, options *ParserOptions) *Report {
+
+	report := &Report{File: filePath, Issues: []Issue{}}
+
+	lenient := ParserOptions{}
+	if options != nil {
+		lenient = *options
+	}
+	lenient.Lenient = true
+
+	api, err := parseFile(filePath, &lenient)
+	report.addError(PhaseParse, err)
+
+	if api != nil {
+		report.add(PhaseParse, api.Warnings)
+
+		_, err = api.Resolve()
+		report.addError(PhaseResolve, err)
+
+		report.add(PhaseValidate, api.Validate().Issues)
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		first, second := report.Issues[i], report.Issues[j]
+		if first.File != second.File {
+			return first.File < second.File
+		}
+		if first.Line != second.Line {
+			return first.Line < second.Line
+		}
+		return first.Column < second.Column
+	})
+
+	return report
+}
+
+// Adds the problems found by a phase, leaving out those found already
+func (report *Report) add(phase Phase, problems []ParseError) {
+
+	for _, problem := range problems {
+
+		duplicate := false
+		for _, issue := range report.Issues {
+			found := issue.ParseError
+			found.NodePath = problem.NodePath
+			if found == problem {
+				duplicate = true
+				break
+			}
+		}
+
+		if !duplicate {
+			report.Issues = append(report.Issues, Issue{problem, phase})
+		}
+	}
+}
+
+// Adds the problems of an error returned by a phase. Errors other than a
+// *RamlError are added as a single problem without a location.
+func (report *Report) addError(phase Phase, err error) {
+
+	var ramlError *RamlError
+	switch {
+	case err == nil:
+	case errors.As(err, &ramlError):
+		report.add(phase, ramlError.Errors)
+	default:
+		report.add(phase, []ParseError{{Message: err.Error(),
+			Severity: SeverityError}})
+	}
+}
+
+// Returns the path of the file an issue is in
+func (report *Report) fileOf(issue Issue) string {
+	if issue.File != "" {
+		return issue.File
+	}
+	return report.File
+}
+
+// Returns the report as text, one issue per line, e.g.
+//
+//	api.raml:12:3: error: trait paged is not declared (validate, undeclared-trait)
+func (report *Report) Text() string {
+
+	var lines []string
+	for _, issue := range report.Issues {
+
+		place := report.fileOf(issue)
+		if issue.Line > 0 {
+			place += fmt.Sprintf(":%d", issue.Line)
+			if issue.Column > 0 {
+				place += fmt.Sprintf(":%d", issue.Column)
+			}
+		}
+
+		origin := string(issue.Phase)
+		if issue.Rule != "" {
+			origin += ", " + issue.Rule
+		}
+
+		lines = append(lines, fmt.Sprintf("%s: %s: %s (%s)", place,
+			issue.Severity, issue.Message, origin))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// The parts of the SARIF 2.1.0 format a Report is rendered in
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId,omitempty"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// Returns the report in the SARIF 2.1.0 format read by code scanning tools,
+// e.g. to annotate pull requests with the issues found. The phase of each
+// issue is kept as a property of its result.
+func (report *Report) SARIF() ([]byte, error) {
+
+	results := []sarifResult{}
+	for _, issue := range report.Issues {
+
+		location := sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{report.fileOf(issue)},
+		}
+		if issue.Line > 0 {
+			location.Region = &sarifRegion{issue.Line, issue.Column}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:     issue.Rule,
+			Level:      string(issue.Severity),
+			Message:    sarifMessage{issue.Message},
+			Locations:  []sarifLocation{{location}},
+			Properties: map[string]string{"phase": string(issue.Phase)},
+		})
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{sarifDriver{"raml",
+				"https://github.com/go-raml/raml"}},
+			Results: results,
+		}},
+	}, "", "  ")
+}
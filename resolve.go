@@ -0,0 +1,414 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to resolving the resource types and
+// traits applied in API definitions into the resources and methods they
+// apply to.
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Resolve returns a copy of the API definition with the resource type of
+// each resource, and the traits applied to each method, merged into them,
+// substituting the parameters given to the resource types and traits.
+// Resources receive the methods of their resource type they don't define
+// themselves.
+//
+// The properties a resource or method defines itself take precedence over
+// those it inherits. Resource types are applied first, so they take
+// precedence over traits, and traits applied first take precedence over
+// traits applied later. Traits applied by a resource come before those
+// applied by the method.
+//
+// The reserved resourcePath and resourcePathName parameters are set to the
+// full path of the resource, e.g. /users/{userId}, and its last segment,
+// e.g. {userId}, both with any {mediaTypeExtension} left out. The reserved
+// methodName parameter of traits is set to the name of the method, e.g. get.
+//
+// Optional properties, e.g. body? or get?, only apply when the resource or
+// method already has the property, whether defined by itself or inherited
+// from its resource type. Otherwise they are dropped.
+func (api *APIDefinition) Resolve() (*APIDefinition, error) {
+	return api.resolve(api.listener)
+}
+
+// Works like Resolve, reporting the traits applied to the given listener
+// rather than the definition's own, e.g. none when checking the definition
+func (api *APIDefinition) resolve(listener ParseListener) (*APIDefinition, error) {
+
+	resolved := api.Copy()
+
+	var err error
+	resolved.updateResources(func(path string, resource *Resource) {
+		if resource.Type == nil || err != nil {
+			return
+		}
+		if err = resolved.applyResourceType(path, resource); err != nil {
+			err = fmt.Errorf("Error applying resource type %s to %s "+
+				"(Error: %s)", resource.Type.Name, path, err.Error())
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Nested resources refer to copies of the top-level resources changed
+	linkResources(resolved)
+
+	resolved.walkResources(func(path string, resource *Resource) {
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil || err != nil {
+				continue
+			}
+
+			for _, choice := range resource.MethodTraits(name) {
+				if err = resolved.applyTrait(path, name, method, choice); err != nil {
+					err = fmt.Errorf("Error applying trait %s to %s %s "+
+						"(Error: %s)", choice.Name, strings.ToUpper(name),
+						path, err.Error())
+					return
+				}
+				listener.notify(ParseEvent{Kind: EventTraitApplied,
+					ResourcePath: path, Method: name, Trait: choice.Name})
+			}
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resolved, nil
+}
+
+// Merges the resource type of the resource at the given path into it
+func (api *APIDefinition) applyResourceType(path string, resource *Resource) error {
+
+	choice := resource.Type
+	resourceType, ok := api.GetResourceType(choice.Name)
+	if !ok {
+		return fmt.Errorf("resource type %s is not declared", choice.Name)
+	}
+
+	value, err := substituteValue(reflect.ValueOf(*resourceType),
+		func(text string) (string, error) {
+			return substituteParameters(text,
+				withReservedParameters(choice.Parameters, path, ""))
+		})
+	if err != nil {
+		return err
+	}
+
+	mergeProperties(reflect.ValueOf(resource).Elem(), value)
+
+	// Methods are of different types in resource types, so aren't merged
+	// by the above
+	substituted := value.Interface().(ResourceType)
+	for _, name := range methodNames {
+
+		typeMethod := substituted.method(name)
+		if typeMethod == nil {
+			continue
+		}
+
+		method := resource.method(name)
+		if method == nil {
+			method = &Method{Name: name}
+			resource.setMethod(name, method)
+		}
+
+		mergeProperties(reflect.ValueOf(method).Elem(),
+			reflect.ValueOf(*typeMethod))
+	}
+
+	// Optional properties apply to what the resource has by now, including
+	// the methods it just inherited
+	mergeOptionalProperties(reflect.ValueOf(resource).Elem(), value)
+	for _, name := range methodNames {
+		typeMethod := substituted.optionalMethod(name)
+		if method := resource.method(name); typeMethod != nil && method != nil {
+			mergeProperties(reflect.ValueOf(method).Elem(),
+				reflect.ValueOf(*typeMethod))
+		}
+	}
+
+	return nil
+}
+
+// Merges the trait applied by the given choice into the method with the
+// given name of the resource at the given path
+func (api *APIDefinition) applyTrait(path, name string, method *Method,
+	choice DefinitionChoice) error {
+
+	trait, ok := api.GetTrait(choice.Name)
+	if !ok {
+		return fmt.Errorf("trait %s is not declared", choice.Name)
+	}
+
+	parameters := withReservedParameters(choice.Parameters, path, name)
+
+	substituted, err := substituteValue(reflect.ValueOf(*trait),
+		func(text string) (string, error) {
+			return substituteParameters(text, parameters)
+		})
+	if err != nil {
+		return err
+	}
+
+	mergeProperties(reflect.ValueOf(method).Elem(), substituted)
+	mergeOptionalProperties(reflect.ValueOf(method).Elem(), substituted)
+	return nil
+}
+
+// Returns the given parameters along with the reserved resourcePath and
+// resourcePathName parameters of the resource at the given path, and the
+// reserved methodName parameter of traits applied to the given method.
+// Resource types are applied without a method name. The reserved values
+// replace any given for them, which the parameter checks report.
+func withReservedParameters(parameters DefinitionParameters,
+	path, method string) DefinitionParameters {
+
+	withReserved := make(DefinitionParameters, len(parameters)+3)
+	for name, value := range parameters {
+		withReserved[name] = value
+	}
+
+	resourcePath := strings.Replace(path, "{mediaTypeExtension}", "", -1)
+	withReserved["resourcePath"] = resourcePath
+	withReserved["resourcePathName"] =
+		resourcePath[strings.LastIndex(resourcePath, "/")+1:]
+	if method != "" {
+		withReserved["methodName"] = method
+	}
+
+	return withReserved
+}
+
+// Returns a deep copy of a value, with the given function applied to every
+// string in it, including map keys. Unexported struct fields are left zero.
+func substituteValue(value reflect.Value,
+	substitute func(string) (string, error)) (reflect.Value, error) {
+
+	substituted := reflect.New(value.Type()).Elem()
+
+	switch value.Kind() {
+	case reflect.String:
+		text, err := substitute(value.String())
+		if err != nil {
+			return substituted, err
+		}
+		substituted.SetString(text)
+
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return substituted, nil
+		}
+		element, err := substituteValue(value.Elem(), substitute)
+		if err != nil {
+			return substituted, err
+		}
+		if value.Kind() == reflect.Ptr {
+			substituted.Set(reflect.New(value.Type().Elem()))
+			substituted.Elem().Set(element)
+		} else {
+			substituted.Set(element)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			field, err := substituteValue(value.Field(i), substitute)
+			if err != nil {
+				return substituted, err
+			}
+			substituted.Field(i).Set(field)
+		}
+
+	case reflect.Slice:
+		if value.IsNil() {
+			return substituted, nil
+		}
+		substituted.Set(reflect.MakeSlice(value.Type(), value.Len(), value.Len()))
+		for i := 0; i < value.Len(); i++ {
+			element, err := substituteValue(value.Index(i), substitute)
+			if err != nil {
+				return substituted, err
+			}
+			substituted.Index(i).Set(element)
+		}
+
+	case reflect.Map:
+		if value.IsNil() {
+			return substituted, nil
+		}
+		substituted.Set(reflect.MakeMap(value.Type()))
+		for _, key := range value.MapKeys() {
+			substitutedKey, err := substituteValue(key, substitute)
+			if err != nil {
+				return substituted, err
+			}
+			element, err := substituteValue(value.MapIndex(key), substitute)
+			if err != nil {
+				return substituted, err
+			}
+			substituted.SetMapIndex(substitutedKey, element)
+		}
+
+	default:
+		substituted.Set(value)
+	}
+
+	return substituted, nil
+}
+
+// The properties of traits that don't apply to the methods they are
+// applied to
+var unmergedProperties = map[string]bool{
+	"Name":  true,
+	"Usage": true,
+}
+
+// Merges the properties of src that dst doesn't define into dst, going
+// down into mappings to merge their entries the same way. Properties are
+// matched by field name, so src may be e.g. a trait and dst a method.
+// Optional properties of src, e.g. body?, are skipped. The values of src
+// end up shared with dst.
+func mergeProperties(dst, src reflect.Value) {
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+
+			field := src.Type().Field(i)
+			if field.PkgPath != "" || unmergedProperties[field.Name] ||
+				strings.HasSuffix(propertyName(field), "?") {
+				continue
+			}
+
+			dstField := dst.FieldByName(field.Name)
+			if dstField.IsValid() && dstField.Type() == field.Type {
+				mergeProperties(dstField, src.Field(i))
+			}
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for _, key := range src.MapKeys() {
+
+			existing := dst.MapIndex(key)
+			if !existing.IsValid() {
+				dst.SetMapIndex(key, src.MapIndex(key))
+				continue
+			}
+
+			// Values held in maps can't be changed in place
+			merged := reflect.New(existing.Type()).Elem()
+			merged.Set(existing)
+			mergeProperties(merged, src.MapIndex(key))
+			dst.SetMapIndex(key, merged)
+		}
+
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(src)
+		} else {
+			mergeProperties(dst.Elem(), src.Elem())
+		}
+
+	case reflect.Slice:
+		if dst.Len() == 0 && src.Len() > 0 {
+			dst.Set(src)
+		}
+
+	default:
+		if dst.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
+
+// Merges the optional properties of src, e.g. body?, into the properties of
+// dst they are named after, e.g. body, like mergeProperties does. Optional
+// properties are only merged when dst already has a value for the property.
+func mergeOptionalProperties(dst, src reflect.Value) {
+
+	for i := 0; i < src.NumField(); i++ {
+
+		field := src.Type().Field(i)
+		name := propertyName(field)
+		if field.PkgPath != "" || !strings.HasSuffix(name, "?") {
+			continue
+		}
+
+		for j := 0; j < dst.NumField(); j++ {
+
+			dstField := dst.Type().Field(j)
+			if propertyName(dstField) != strings.TrimSuffix(name, "?") ||
+				dstField.Type != field.Type {
+				continue
+			}
+
+			if value := dst.Field(j); hasValue(value) {
+				mergeProperties(value, src.Field(i))
+			}
+		}
+	}
+}
+
+// Returns the name of the RAML property a struct field is decoded from,
+// e.g. body? for Trait.OptionalBodies
+func propertyName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("yaml"), ",")[0]
+}
+
+// Reports whether a property has a value, treating empty mappings and
+// sequences as missing
+func hasValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Map, reflect.Slice:
+		return value.Len() > 0
+	}
+	return !value.IsZero()
+}
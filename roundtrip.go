@@ -0,0 +1,226 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to comparing the schemas an API
+// definition gives the same entity when it is sent and when it is returned.
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A field that only one of the schemas of an entity defines: either the
+// schema of the body POSTed to a collection, or the schema of the entity
+// the API returns when getting it
+type SchemaDrift struct {
+
+	// The full URI templates of the resource the entity is POSTed to and of
+	// the resource it is gotten from, e.g. /users and /users/{userId}
+	RequestPath  string
+	ResponsePath string
+
+	// The media type of both bodies, or empty for the bodies' default
+	// schemas
+	MediaType string
+
+	// The JSON pointer of the field in the entity, e.g. /address/city
+	Field string
+
+	// Whether the field is only in the request schema, rather than only in
+	// the response schema
+	InRequest bool
+}
+
+// Matches the relative URI of a member of a collection, e.g. /{userId}
+var memberURIRegexp = regexp.MustCompile(`^/{[^/{}]+}$`)
+
+// SchemaDrift compares the JSON schema of the body POSTed to each resource
+// with the JSON schema of the entity returned by a 200 response to GET on
+// the resource itself or on its members, e.g. /users/{userId}. When GET on
+// the resource returns an array, its items are compared. Fields marked
+// readOnly in the response, e.g. generated identifiers, and fields marked
+// writeOnly in the request, e.g. passwords, are expected on one side only.
+// Returns the fields found on one side only, ordered by resource paths,
+// media type and field.
+func (api *APIDefinition) SchemaDrift() []SchemaDrift {
+
+	var drifts []SchemaDrift
+
+	api.walkResources(func(path string, resource *Resource) {
+
+		if resource.Post == nil {
+			return
+		}
+
+		compare := func(responsePath string, get *Method) {
+			if get == nil {
+				return
+			}
+			response, ok := get.Responses[200]
+			if !ok {
+				return
+			}
+
+			drifts = append(drifts, api.bodySchemaDrift(path, responsePath, "",
+				resource.Post.Bodies.DefaultSchema,
+				response.Bodies.DefaultSchema)...)
+
+			var mediaTypes []string
+			for mediaType := range resource.Post.Bodies.ForMIMEType {
+				mediaTypes = append(mediaTypes, mediaType)
+			}
+			sort.Strings(mediaTypes)
+
+			for _, mediaType := range mediaTypes {
+				drifts = append(drifts, api.bodySchemaDrift(path,
+					responsePath, mediaType,
+					resource.Post.Bodies.ForMIMEType[mediaType].Schema,
+					response.Bodies.ForMIMEType[mediaType].Schema)...)
+			}
+		}
+
+		compare(path, resource.Get)
+
+		var uris []string
+		for uri := range resource.Nested {
+			if memberURIRegexp.MatchString(uri) {
+				uris = append(uris, uri)
+			}
+		}
+		sort.Strings(uris)
+
+		for _, uri := range uris {
+			if member := resource.Nested[uri]; member != nil {
+				compare(path+uri, member.Get)
+			}
+		}
+	})
+
+	return drifts
+}
+
+// Returns the fields only one of a request and a response schema defines,
+// or nothing unless both are JSON schemas of objects
+func (api *APIDefinition) bodySchemaDrift(requestPath, responsePath,
+	mediaType, requestSchema, responseSchema string) []SchemaDrift {
+
+	request := decodeObjectSchema(api.schemaText(requestSchema))
+	response := decodeObjectSchema(api.schemaText(responseSchema))
+	if request == nil || response == nil {
+		return nil
+	}
+
+	// Collection responses hold the entities in an array
+	if items, ok := response["items"].(map[string]interface{}); ok {
+		response = items
+	}
+
+	requestFields := make(map[string]bool)
+	collectSchemaFields(request, "", "writeOnly", requestFields)
+	responseFields := make(map[string]bool)
+	collectSchemaFields(response, "", "readOnly", responseFields)
+
+	var fields []string
+	for field := range requestFields {
+		fields = append(fields, field)
+	}
+	for field := range responseFields {
+		if _, ok := requestFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var drifts []SchemaDrift
+	for _, field := range fields {
+
+		expected, inRequest := requestFields[field]
+		if _, inResponse := responseFields[field]; inResponse {
+			if inRequest {
+				continue
+			}
+			expected = responseFields[field]
+		}
+
+		if !expected {
+			drifts = append(drifts, SchemaDrift{requestPath, responsePath,
+				mediaType, field, inRequest})
+		}
+	}
+
+	return drifts
+}
+
+// Returns a body schema, looking it up in the root-level schemas property
+// if the body names a declared schema
+func (api *APIDefinition) schemaText(schema string) string {
+	name := strings.TrimSpace(schema)
+	for _, schemas := range api.Schemas {
+		if declared, ok := schemas[name]; ok {
+			return declared
+		}
+	}
+	return schema
+}
+
+// Decodes a JSON schema, or returns nil if it isn't JSON or isn't an object
+func decodeObjectSchema(schema string) map[string]interface{} {
+	var decoded map[string]interface{}
+	if json.Unmarshal([]byte(schema), &decoded) != nil {
+		return nil
+	}
+	return decoded
+}
+
+// Adds the JSON pointers of the properties a decoded JSON schema defines,
+// and those of their own properties, to fields. The value tells whether the
+// property has the given marker set, e.g. readOnly, which means it is
+// expected on one side only.
+func collectSchemaFields(schema map[string]interface{}, pointer string,
+	marker string, fields map[string]bool) {
+
+	properties, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for name, property := range properties {
+
+		property, ok := property.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		field := pointer + "/" + name
+		fields[field] = property[marker] == true
+		collectSchemaFields(property, field, marker, fields)
+	}
+}
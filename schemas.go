@@ -0,0 +1,226 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to the schemas of API definitions.
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Reports whether a body schema defines a schema inline, rather than naming
+// one declared in the root-level schemas property
+func isInlineSchema(schema string) bool {
+	schema = strings.TrimSpace(schema)
+	return schema != "" && !schemaNameRegexp.MatchString(schema)
+}
+
+// Reports whether the node with the given path holds a schema: either a
+// body schema, or one declared at the root
+func isSchemaNode(nodePath string) bool {
+	return path.Base(nodePath) == "schema" || path.Dir(nodePath) == "/schemas"
+}
+
+// A schema defined inline by several bodies, which ExtractSchemas declares
+// once for all of them
+type SharedSchema struct {
+
+	// The name ExtractSchemas declares the schema under, e.g.
+	// usersUserIdGetResponse200
+	Name string
+
+	// The schema as the first body defines it
+	Schema string
+
+	// The paths of the schema nodes defining it, e.g.
+	// /resources//users/get/responses/200/body/application/json/schema
+	NodePaths []string
+}
+
+// ExtractSchemas moves the schemas defined inline in request and response
+// bodies to the root-level schemas property, and makes the bodies refer to
+// them by name instead. Bodies defining the same schema end up sharing it;
+// JSON schemas are the same when they have the same structure, whatever
+// their white space and the order of their properties. Schemas are named
+// after where they were first found, e.g. usersUserIdGetResponse200 for the
+// response to GET /users/{userId}. Returns a copy of the API definition with
+// the schemas extracted, and the names of the schemas added. The definition
+// itself is left as it is.
+func (api *APIDefinition) ExtractSchemas() (*APIDefinition, []string) {
+	extracted, added, _ := api.extractSchemas()
+	return extracted, added
+}
+
+// SharedSchemas returns the schemas defined inline by more than one body,
+// in the order ExtractSchemas adds them, so that exporters and code
+// generators can make one type of each rather than one per body.
+func (api *APIDefinition) SharedSchemas() []SharedSchema {
+
+	extracted, added, nodePaths := api.extractSchemas()
+	if len(added) == 0 {
+		return nil
+	}
+	schemas := extracted.Schemas[len(extracted.Schemas)-1]
+
+	var shared []SharedSchema
+	for _, name := range added {
+		if len(nodePaths[name]) > 1 {
+			shared = append(shared, SharedSchema{Name: name,
+				Schema: schemas[name], NodePaths: nodePaths[name]})
+		}
+	}
+	return shared
+}
+
+// Extracts the inline schemas like ExtractSchemas, and also returns the
+// paths of the schema nodes defining each schema added, by name
+func (api *APIDefinition) extractSchemas() (*APIDefinition, []string,
+	map[string][]string) {
+
+	extracted := api.Copy()
+
+	declared := make(map[string]bool)
+	for _, declaredSchemas := range extracted.Schemas {
+		for name := range declaredSchemas {
+			declared[name] = true
+		}
+	}
+
+	schemas := make(map[string]string)
+	names := make(map[string]string)
+	nodePaths := make(map[string][]string)
+	var added []string
+
+	// Returns the name of the schema of the given node, extracting it under
+	// a name made of the given words when first seen
+	extract := func(schema, nodePath string, words ...string) string {
+
+		if !isInlineSchema(schema) {
+			return schema
+		}
+
+		key := schemaKey(schema)
+		if name, ok := names[key]; ok {
+			nodePaths[name] = append(nodePaths[name], nodePath)
+			return name
+		}
+
+		base := lowerCamelCase(strings.Join(words, " "))
+		name := base
+		for i := 2; declared[name]; i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+
+		declared[name] = true
+		names[key] = name
+		schemas[name] = schema
+		nodePaths[name] = []string{nodePath}
+		added = append(added, name)
+
+		return name
+	}
+
+	extractBodies := func(bodies *Bodies, nodePath string, words ...string) {
+
+		bodies.DefaultSchema = extract(bodies.DefaultSchema,
+			nodePath+"/schema", words...)
+
+		var mediaTypes []string
+		for mediaType := range bodies.ForMIMEType {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+		sort.Strings(mediaTypes)
+
+		// Only tell bodies apart by media type when there are several
+		for _, mediaType := range mediaTypes {
+			body := bodies.ForMIMEType[mediaType]
+			schemaPath := nodePath + "/" + mediaType + "/schema"
+			if len(mediaTypes) > 1 {
+				body.Schema = extract(body.Schema, schemaPath, append(words,
+					mediaTypeWords(mediaType)...)...)
+			} else {
+				body.Schema = extract(body.Schema, schemaPath, words...)
+			}
+			bodies.ForMIMEType[mediaType] = body
+		}
+	}
+
+	extracted.updateBodies(func(path, method string, code HTTPCode, nodePath string,
+		bodies *Bodies) {
+
+		words := strings.FieldsFunc(path, func(r rune) bool {
+			return r == '/' || r == '{' || r == '}'
+		})
+		words = append(words, method)
+
+		if code == 0 {
+			extractBodies(bodies, nodePath, append(words, "request")...)
+		} else {
+			extractBodies(bodies, nodePath, append(words, "response",
+				fmt.Sprint(int(code)))...)
+		}
+	})
+
+	if len(schemas) > 0 {
+		extracted.Schemas = append(extracted.Schemas, schemas)
+	}
+
+	return extracted, added, nodePaths
+}
+
+// Returns what tells schemas apart: the structure of JSON schemas, encoded
+// again with their object keys ordered and without white space, or the
+// text of other schemas
+func schemaKey(schema string) string {
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(schema), &value); err != nil {
+		return strings.TrimSpace(schema)
+	}
+
+	key, err := json.Marshal(value)
+	if err != nil {
+		return strings.TrimSpace(schema)
+	}
+	return string(key)
+}
+
+// Returns the words naming a media type, e.g. "vnd", "api", "json" for
+// application/vnd.api+json
+func mediaTypeWords(mediaType string) []string {
+	if slash := strings.Index(mediaType, "/"); slash >= 0 {
+		mediaType = mediaType[slash+1:]
+	}
+	return strings.FieldsFunc(mediaType, func(r rune) bool {
+		return r == '.' || r == '+' || r == '-'
+	})
+}
@@ -0,0 +1,217 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to checking API definitions for
+// security weaknesses, e.g. as part of a security review.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The methods changing the resources they are called on
+var mutatingMethods = map[string]bool{
+	"post": true, "put": true, "patch": true, "delete": true,
+}
+
+// Words naming values too sensitive to send in query strings, which end up
+// in server logs, browser histories and Referer headers
+var sensitiveWords = []string{"password", "passwd", "secret", "token",
+	"apikey", "accesskey", "privatekey", "ssn", "creditcard", "cardnumber",
+	"cvv"}
+
+// Checks that every method is secured, that methods changing data can't be
+// called anonymously, that secured methods declare the responses to failed
+// authentication and authorization, that sensitive values aren't sent in
+// query strings, and that OAuth 2.0 schemes don't only allow the implicit
+// grant. Security schemes are looked up once resource types and traits are
+// applied.
+func checkSecurity(api *APIDefinition) []ParseError {
+
+	var warnings []ParseError
+	report := problemReporter(&warnings, SeverityWarning)
+
+	// Definitions that can't be resolved are reported by checkReferences,
+	// so check what they declare themselves instead
+	resolved, err := api.resolve(nil)
+	if err != nil {
+		resolved = api
+	}
+
+	var names []string
+	for name := range resolved.declarations().securitySchemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		scheme, _ := resolved.GetSecurityScheme(name)
+		if isImplicitGrantOnly(scheme) {
+			report("implicit-grant-only", "/securitySchemes/"+name+
+				"/settings/authorizationGrants", "security scheme %s only "+
+				"allows the implicit grant, which hands access tokens out in "+
+				"redirect URIs; allow the code grant", name)
+		}
+	}
+
+	resolved.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil {
+				continue
+			}
+			methodPath := nodePath + "/" + name
+
+			// Methods are secured by their own schemes, or else by those
+			// of their resource, or else by those of the API
+			securedBy := method.SecuredBy
+			if len(securedBy) == 0 {
+				securedBy = resource.SecuredBy
+			}
+			if len(securedBy) == 0 {
+				securedBy = resolved.SecuredBy
+			}
+
+			var schemes []*SecurityScheme
+			anonymous := len(securedBy) == 0
+			for _, choice := range securedBy {
+				if choice.Name == "" || choice.Name == "null" {
+					anonymous = true
+				} else if scheme, ok := resolved.GetSecurityScheme(choice.Name); ok {
+					schemes = append(schemes, scheme)
+				}
+			}
+
+			switch {
+			case anonymous && mutatingMethods[name]:
+				report("unauthenticated-mutation", methodPath, "method %s of "+
+					"resource %s changes data but may be called without "+
+					"authentication; secure it with a security scheme",
+					name, uri)
+			case len(securedBy) == 0:
+				report("unsecured-method", methodPath, "method %s of "+
+					"resource %s is not secured by any security scheme; "+
+					"apply securedBy to it, its resource or the API, or "+
+					"securedBy: [ null ] if it is public on purpose",
+					name, uri)
+			}
+
+			if len(schemes) > 0 && len(method.Responses) > 0 {
+				var missing []HTTPCode
+				for _, code := range []HTTPCode{401, 403} {
+					if !declaresResponse(method, schemes, code) {
+						missing = append(missing, code)
+					}
+				}
+				if len(missing) > 0 {
+					noun := "response"
+					if len(missing) > 1 {
+						noun = "responses"
+					}
+					report("undeclared-auth-responses", methodPath+
+						"/responses", "secured method %s of resource %s "+
+						"declares no %s %s; document how failed "+
+						"authentication and authorization are answered",
+						name, uri, joinCodes(missing), noun)
+				}
+			}
+
+			var queryNames []string
+			for queryName := range method.QueryParameters {
+				queryNames = append(queryNames, queryName)
+			}
+			sort.Strings(queryNames)
+
+			for _, queryName := range queryNames {
+				if word := sensitiveWord(queryName); word != "" {
+					report("sensitive-query-parameter", methodPath+
+						"/queryParameters/"+queryName, "query parameter %s "+
+						"of method %s of resource %s is named like a secret "+
+						"(%s); send it in a header or the body, as query "+
+						"strings are logged", queryName, name, uri, word)
+				}
+			}
+		}
+	})
+
+	return warnings
+}
+
+// Reports whether the method, or any of the security schemes securing it,
+// declares a response with the given status code
+func declaresResponse(method *Method, schemes []*SecurityScheme,
+	code HTTPCode) bool {
+
+	if _, ok := method.Responses[code]; ok {
+		return true
+	}
+	for _, scheme := range schemes {
+		if _, ok := scheme.DescribedBy.Responses[code]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the sensitive word a parameter name contains, e.g. password for
+// user_password, or nothing
+func sensitiveWord(name string) string {
+
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "").
+		Replace(name))
+
+	for _, word := range sensitiveWords {
+		if strings.Contains(normalized, word) {
+			return word
+		}
+	}
+	return ""
+}
+
+// Reports whether an OAuth 2.0 scheme allows the implicit grant, named
+// token, and no other
+func isImplicitGrantOnly(scheme *SecurityScheme) bool {
+
+	if scheme.Type != "OAuth 2.0" {
+		return false
+	}
+
+	grants, ok := scheme.Settings["authorizationGrants"].([]interface{})
+	if !ok || len(grants) == 0 {
+		return false
+	}
+	for _, grant := range grants {
+		if fmt.Sprint(grant) != "token" {
+			return false
+		}
+	}
+	return true
+}
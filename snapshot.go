@@ -0,0 +1,144 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to saving and loading snapshots of
+// parsed API definitions.
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The version of the snapshot format written by Save. Bump this whenever
+// the APIDefinition type changes in a way gob can't bridge by itself, and
+// add a migration from the previous version to snapshotMigrations.
+//
+// Version 2 keeps the minimum and maximum of named parameters as exact
+// numbers rather than float64s. Version 1 snapshots can't be decoded into
+// these, so they can't be loaded anymore.
+const SnapshotVersion = 2
+
+// Identifies a snapshot stream
+const snapshotMagic = "RAML-SNAPSHOT"
+
+// Written before the API definition in every snapshot
+type snapshotHeader struct {
+	Magic   string
+	Version int
+}
+
+// Migrations upgrading an API definition loaded from a snapshot of the given
+// version to the next version. Load applies them in order until the
+// definition is at SnapshotVersion.
+var snapshotMigrations = map[int]func(*APIDefinition) error{}
+
+func init() {
+	// Values of type Any hold whatever the YAML decoder produced, so the
+	// generic YAML container types must be known to gob.
+	gob.Register([]interface{}{})
+	gob.Register(map[interface{}]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// Save writes a binary snapshot of the API definition to w. The snapshot can
+// be read back with Load, which is much faster than parsing the RAML again.
+func (api *APIDefinition) Save(w io.Writer) error {
+
+	encoder := gob.NewEncoder(w)
+
+	header := snapshotHeader{Magic: snapshotMagic, Version: SnapshotVersion}
+	if err := encoder.Encode(&header); err != nil {
+		return fmt.Errorf("Error writing snapshot header (Error: %s)",
+			err.Error())
+	}
+
+	// Resources refer back to their parents, which gob can't encode. The
+	// parents are set again when loading.
+	snapshot := api.Copy()
+	unlinkResources(snapshot)
+
+	if err := encoder.Encode(snapshot); err != nil {
+		return fmt.Errorf("Error writing snapshot (Error: %s)", err.Error())
+	}
+
+	return nil
+}
+
+// Load reads an API definition snapshot written by Save. Snapshots written
+// by older versions of this package are migrated to the current format;
+// snapshots written by newer versions are rejected.
+func Load(r io.Reader) (*APIDefinition, error) {
+
+	decoder := gob.NewDecoder(r)
+
+	var header snapshotHeader
+	if err := decoder.Decode(&header); err != nil {
+		return nil, fmt.Errorf("Error reading snapshot header (Error: %s)",
+			err.Error())
+	}
+
+	if header.Magic != snapshotMagic {
+		return nil, errors.New("Input is not a RAML snapshot")
+	}
+
+	if header.Version > SnapshotVersion {
+		return nil, fmt.Errorf("Snapshot version %d is newer than the "+
+			"supported version %d", header.Version, SnapshotVersion)
+	}
+
+	// Snapshots without migrations to bring them up to date may not even
+	// decode, so refuse them first
+	for version := header.Version; version < SnapshotVersion; version++ {
+		if _, ok := snapshotMigrations[version]; !ok {
+			return nil, fmt.Errorf("Snapshot version %d is no longer "+
+				"supported, parse the RAML again to make a version %d "+
+				"snapshot", header.Version, SnapshotVersion)
+		}
+	}
+
+	apiDefinition := new(APIDefinition)
+	if err := decoder.Decode(apiDefinition); err != nil {
+		return nil, fmt.Errorf("Error reading snapshot (Error: %s)",
+			err.Error())
+	}
+
+	// Bring older snapshots up to date
+	for version := header.Version; version < SnapshotVersion; version++ {
+		if err := snapshotMigrations[version](apiDefinition); err != nil {
+			return nil, fmt.Errorf("Error migrating snapshot from version "+
+				"%d (Error: %s)", version, err.Error())
+		}
+	}
+
+	linkResources(apiDefinition)
+
+	return apiDefinition, nil
+}
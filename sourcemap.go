@@ -0,0 +1,321 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to mapping the lines of a
+// preprocessed RAML document back to the files they came from.
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Where a line of a preprocessed RAML document came from
+type sourceLocation struct {
+
+	// The path of the included file, or empty for the main document
+	File string
+
+	// The line number within that file, starting at 1
+	Line int
+
+	// How many columns of indentation preprocessing added to the line
+	Indentation int
+}
+
+// Holds the source location of every line of a preprocessed RAML document,
+// by line index
+type sourceMap []sourceLocation
+
+// Returns where the given line of the preprocessed document came from. Lines
+// outside the map are assumed to be in the main document.
+func (m sourceMap) locate(line int) sourceLocation {
+	if line < 1 || line > len(m) {
+		return sourceLocation{Line: line}
+	}
+	return m[line-1]
+}
+
+// Matches the key of a block mapping entry at the start of a line, with
+// sequence indicators and indentation already removed
+var mappingKeyRegexp = regexp.MustCompile(
+	`^(?:"([^"]*)"|'([^']*)'|([^\s#'"{\[][^#]*?)):(?:\s|$)`)
+
+// Locates the problems found in a preprocessed RAML document in the files
+// it was made of, and the YAML nodes they are in
+type documentIndex struct {
+	locations sourceMap
+
+	// The node path and key column of each line, by line index. Lines
+	// without a key belong to the node enclosing them, and have no column.
+	nodePaths []string
+	columns   []int
+
+	// The first line of each node path
+	nodeLines map[string]int
+
+	// The keys declared more than once in the same mapping
+	duplicates []duplicateKey
+}
+
+// A key declared more than once in the same mapping, which the YAML decoder
+// silently lets the last declaration win for
+type duplicateKey struct {
+	key string
+
+	// The lines of the duplicate and the first declaration
+	line      int
+	firstLine int
+}
+
+// Indexes the node paths of a preprocessed document. This follows the
+// indentation of block mappings, which is all RAML documents need, rather
+// than fully parsing the YAML again.
+func newDocumentIndex(document []byte, locations sourceMap) *documentIndex {
+
+	index := &documentIndex{
+		locations: locations,
+		nodeLines: make(map[string]int),
+	}
+
+	// The keys enclosing the current line, with their columns and the
+	// lines of the keys of their mapping values
+	type enclosingKey struct {
+		key      string
+		column   int
+		children map[string]int
+	}
+	var enclosing []enclosingKey
+	rootKeys := make(map[string]int)
+
+	pathOf := func(keys []enclosingKey) string {
+		var path bytes.Buffer
+		for i, key := range keys {
+			path.WriteByte('/')
+
+			// Top level resources are kept apart from the other
+			// properties of the API definition
+			if i == 0 && strings.HasPrefix(key.key, "/") {
+				path.WriteString("resources/")
+			}
+
+			path.WriteString(key.key)
+		}
+		return path.String()
+	}
+
+	var blockScalars blockScalarTracker
+	var flows flowTracker
+	scanner := bufio.NewScanner(bytes.NewBuffer(document))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Block scalar contents, the continuation lines of quoted scalars
+		// and flow collections, and blank lines belong to the current node
+		trimmed := strings.TrimSpace(line)
+		if flows.inside() {
+			flows.scan(trimmed)
+			index.nodePaths = append(index.nodePaths, pathOf(enclosing))
+			index.columns = append(index.columns, 0)
+			continue
+		}
+		if blockScalars.contains(line) || trimmed == "" ||
+			strings.HasPrefix(trimmed, "#") {
+			index.nodePaths = append(index.nodePaths, pathOf(enclosing))
+			index.columns = append(index.columns, 0)
+			continue
+		}
+
+		// Skip indentation and sequence indicators to find the key
+		column := len(line) - len(strings.TrimLeft(line, " "))
+		entry := false
+		for strings.HasPrefix(line[column:], "- ") {
+			column += 2
+			column += len(line[column:]) - len(strings.TrimLeft(line[column:], " "))
+			entry = true
+		}
+
+		// Leave the mappings this line isn't nested in
+		for len(enclosing) > 0 && enclosing[len(enclosing)-1].column >= column+1 {
+			enclosing = enclosing[:len(enclosing)-1]
+		}
+
+		// Every sequence entry is a mapping of its own, even when its keys
+		// start on the next line
+		if entry && len(enclosing) > 0 {
+			enclosing[len(enclosing)-1].children = make(map[string]int)
+		}
+
+		match := mappingKeyRegexp.FindStringSubmatch(line[column:])
+		if match == nil {
+			flows.scan(strings.TrimSpace(line[column:]))
+			index.nodePaths = append(index.nodePaths, pathOf(enclosing))
+			index.columns = append(index.columns, 0)
+			continue
+		}
+
+		key := strings.TrimSpace(match[1] + match[2] + match[3])
+		flows.scan(strings.TrimSpace(line[column+len(match[0]):]))
+		lineNumber := len(index.nodePaths) + 1
+
+		siblings := rootKeys
+		if len(enclosing) > 0 {
+			siblings = enclosing[len(enclosing)-1].children
+		}
+
+		if firstLine, ok := siblings[key]; ok {
+			index.duplicates = append(index.duplicates,
+				duplicateKey{key, lineNumber, firstLine})
+		} else {
+			siblings[key] = lineNumber
+		}
+
+		enclosing = append(enclosing,
+			enclosingKey{key, column + 1, make(map[string]int)})
+
+		path := pathOf(enclosing)
+		if _, ok := index.nodeLines[path]; !ok {
+			index.nodeLines[path] = lineNumber
+		}
+		index.nodePaths = append(index.nodePaths, path)
+		index.columns = append(index.columns, column+1)
+	}
+
+	return index
+}
+
+// Follows the quoted scalars and flow collections of a YAML document across
+// lines, as their continuation lines may well look like mapping keys, e.g.
+// those of an inlined JSON example
+type flowTracker struct {
+
+	// The quote of the quoted scalar being scanned, or 0 if none
+	quote byte
+
+	// How many flow collections are open
+	depth int
+}
+
+// Reports whether the previous lines left a quoted scalar or flow
+// collection open
+func (tracker *flowTracker) inside() bool {
+	return tracker.quote != 0 || tracker.depth > 0
+}
+
+// Follows the quotes and brackets of a value, or of a continuation line.
+// Plain scalars are skipped, as they may contain quotes freely.
+func (tracker *flowTracker) scan(text string) {
+
+	if !tracker.inside() &&
+		(text == "" || !strings.ContainsRune(`'"{[`, rune(text[0]))) {
+		return
+	}
+
+	// The last character outside quoted scalars, which tells whether a
+	// quote inside a flow collection starts a quoted scalar
+	previous := byte('[')
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case tracker.quote == '\'':
+			if c == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					i++
+				} else {
+					tracker.quote = 0
+				}
+			}
+			continue
+		case tracker.quote == '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				tracker.quote = 0
+			}
+			continue
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			// The rest of the line is a comment
+			return
+		case (c == '\'' || c == '"') && strings.IndexByte("[{,:", previous) >= 0:
+			tracker.quote = c
+		case c == '[' || c == '{':
+			tracker.depth++
+		case (c == ']' || c == '}') && tracker.depth > 0:
+			tracker.depth--
+		}
+
+		if c != ' ' {
+			previous = c
+		}
+	}
+}
+
+// Fills in where a problem found in the preprocessed document is. Problems
+// refer either to a line of the preprocessed document or to a node path;
+// the file, line, column and node path of the problem are set from
+// whichever is known.
+func (index *documentIndex) resolve(problem ParseError) ParseError {
+
+	line := problem.Line
+	if line == 0 {
+		line = index.nodeLines[problem.NodePath]
+	}
+
+	if line < 1 || line > len(index.nodePaths) {
+		return problem
+	}
+
+	location := index.locations.locate(line)
+	problem.File = location.File
+	problem.Line = location.Line
+
+	if problem.NodePath == "" {
+		problem.NodePath = index.nodePaths[line-1]
+	}
+
+	if column := index.columns[line-1]; column > 0 {
+		problem.Column = column - location.Indentation
+	}
+
+	return problem
+}
+
+// Returns the node path of the given line of an original file, or an empty
+// string if it isn't part of the document
+func (index *documentIndex) nodePathOf(file string, line int) string {
+	for i, location := range index.locations {
+		if location.File == file && location.Line == line {
+			return index.nodePaths[i]
+		}
+	}
+	return ""
+}
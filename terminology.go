@@ -0,0 +1,137 @@
+// Copyright 2014 DoAT. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without modification,
+// are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+//    this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation and/or
+//    other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED “AS IS” WITHOUT ANY WARRANTIES WHATSOEVER.
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+// THE IMPLIED WARRANTIES OF NON INFRINGEMENT, MERCHANTABILITY AND FITNESS FOR A
+// PARTICULAR PURPOSE ARE HEREBY DISCLAIMED. IN NO EVENT SHALL DoAT OR CONTRIBUTORS
+// BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// // THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+// NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE,
+// EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+//
+// The views and conclusions contained in the software and documentation are those of
+// the authors and should not be interpreted as representing official policies,
+// either expressed or implied, of DoAT.
+
+package raml
+
+// This file contains all code related to checking the wording of API
+// definitions against an organization's terminology.
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"sort"
+)
+
+// The kinds of text a TermChecker is given
+type TextKind string
+
+const (
+	// The description of any node, e.g. of a resource or method
+	TextDescription TextKind = "description"
+
+	// The name of a property defined by a JSON schema
+	TextSchemaProperty TextKind = "schemaProperty"
+)
+
+// A TermChecker enforces an organization's vocabulary, e.g. banned words,
+// glossary terms or field naming rules. It is given a text of the API
+// definition and returns a description of each problem with it.
+type TermChecker func(kind TextKind, text string) []string
+
+// CheckTerms runs every description, and the property names of every JSON
+// schema, through the checker. The problems found are reported as warnings
+// of the terminology rule, located like those found by Validate.
+func (api *APIDefinition) CheckTerms(checker TermChecker) *ValidationReport {
+
+	report := &ValidationReport{Issues: []ParseError{}}
+	add := problemReporter(&report.Issues, SeverityWarning)
+
+	check := func(nodePath string, kind TextKind, text string) {
+		for _, problem := range checker(kind, text) {
+			add("terminology", nodePath, "%s", problem)
+		}
+	}
+
+	walkNodes(reflect.ValueOf(api), "", func(nodePath string, value reflect.Value) {
+
+		if value.Kind() != reflect.String || value.String() == "" {
+			return
+		}
+
+		switch {
+		case path.Base(nodePath) == "description":
+			check(nodePath, TextDescription, value.String())
+
+		case isSchemaNode(nodePath):
+			for _, name := range jsonSchemaProperties(value.String()) {
+				check(nodePath, TextSchemaProperty, name)
+			}
+		}
+	})
+
+	if api.index != nil {
+		for i, issue := range report.Issues {
+			report.Issues[i] = api.index.resolve(issue)
+		}
+	}
+
+	return report
+}
+
+// Returns the names of the properties defined anywhere in a JSON schema,
+// ordered and without duplicates. Returns nothing for schemas that aren't
+// JSON, e.g. XML schemas or names of declared schemas.
+func jsonSchemaProperties(schema string) []string {
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(schema), &decoded); err != nil {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	collectSchemaProperties(decoded, names)
+
+	var properties []string
+	for name := range names {
+		properties = append(properties, name)
+	}
+	sort.Strings(properties)
+
+	return properties
+}
+
+// Adds the keys of every properties object found in a decoded JSON schema
+func collectSchemaProperties(value interface{}, names map[string]bool) {
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if properties, ok := child.(map[string]interface{}); ok &&
+				key == "properties" {
+				for name := range properties {
+					names[name] = true
+				}
+			}
+			collectSchemaProperties(child, names)
+		}
+	case []interface{}:
+		for _, child := range value {
+			collectSchemaProperties(child, names)
+		}
+	}
+}
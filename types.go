@@ -28,12 +28,23 @@
 // This package contains the parser, validator and types that implement the
 // RAML specification, as documented here:
 // http://raml.org/spec.html
+//
+// The API definitions returned by the parser are never modified by this
+// package afterwards, so a single parsed definition can be shared by any
+// number of goroutines reading it, without locking. Operations deriving a
+// different definition work on a copy, see APIDefinition.Copy. Callers
+// modifying a shared definition should do the same.
 package raml
 
 // This file contains all of the RAML types.
 
-// TODO: We don't support !include of non-text files. RAML supports including
-//       of many file types.
+import (
+	"fmt"
+	"math/big"
+	"net/textproto"
+	"sort"
+	"strings"
+)
 
 // "Any" type, for our convenience
 type Any interface{}
@@ -42,6 +53,56 @@ type Any interface{}
 type HTTPCode int      // e.g. 200
 type HTTPHeader string // e.g. Content-Length
 
+// A number exactly as written in the RAML document, e.g. 9007199254740993
+// or 0.10. Unlike a float64, it does not lose precision on large integers
+// or decimal amounts.
+type Number string
+
+// Unmarshal a numeric scalar, keeping its literal text. Only scalars YAML
+// itself resolves to integers or floats are numbers, so quoted strings and
+// fractions such as 1/3 aren't.
+func (n *Number) UnmarshalYAML(unmarshaler func(interface{}) error) error {
+
+	var literal string
+	if err := unmarshaler(&literal); err != nil {
+		return err
+	}
+
+	var value interface{}
+	if err := unmarshaler(&value); err != nil {
+		return err
+	}
+
+	switch value.(type) {
+	case int, int64, uint64, float64:
+		if _, ok := new(big.Rat).SetString(literal); ok {
+			*n = Number(literal)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q is not a number", literal)
+}
+
+// Returns the exact value of the number
+func (n Number) Rat() *big.Rat {
+	value, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return new(big.Rat)
+	}
+	return value
+}
+
+// Returns the nearest float64 value of the number
+func (n Number) Float64() float64 {
+	value, _ := n.Rat().Float64()
+	return value
+}
+
+func (n Number) String() string {
+	return string(n)
+}
+
 // The RAML Specification uses collections of named parameters for the
 // following properties: URI parameters, query string parameters, form
 // parameters, request bodies (depending on the media type), and request
@@ -56,7 +117,7 @@ type NamedParameter struct {
 
 	// The name of the Parameter, as defined by the type containing it.
 	Name string
-	// TODO: Fill this during the post-processing phase
+	// Set from its key in the RAML document when parsing
 
 	// A friendly name used only for display or documentation purposes.
 	// If displayName is not specified, it defaults to the property's key
@@ -98,11 +159,11 @@ type NamedParameter struct {
 
 	// The minimum attribute specifies the parameter's minimum value. (numbers
 	// only)
-	Minimum *float64
+	Minimum *Number
 
 	// The maximum attribute specifies the parameter's maximum value. (numbers
 	// only)
-	Maximum *float64
+	Maximum *Number
 
 	// An example value for the property. This can be used, e.g., by
 	// documentation generators to generate sample values for the property.
@@ -230,7 +291,7 @@ type Response struct {
 
 	// HTTP status code of the response
 	HTTPCode HTTPCode
-	// TODO: Fill this during the post-processing phase
+	// Set from its key in the RAML document when parsing
 
 	// Clarifies why the response was emitted. Response descriptions are
 	// particularly useful for describing error conditions.
@@ -264,6 +325,21 @@ type DefinitionChoice struct {
 	// case its value is provided by the processing application.
 	// Same goes for security schemes.
 	Parameters DefinitionParameters
+
+	// When a trait is applied to a resource, the names of the methods it
+	// applies to (only these), or doesn't apply to (all but these). This
+	// is an extension to RAML, written as the (only) and (except)
+	// parameters of the trait, e.g.:
+	//
+	//	is: [ secured: { (except): [ get ] } ]
+	Only   []string
+	Except []string
+
+	// The parameters given values of the wrong kind, e.g. lists, with those
+	// values. They are left out of the above and reported by the parameter
+	// checks, so that decoding can go on. Copies of the definition don't
+	// keep them.
+	invalid map[string]Any
 }
 
 // Unmarshal a node which MIGHT be a simple string or a
@@ -271,7 +347,7 @@ type DefinitionChoice struct {
 func (dc *DefinitionChoice) UnmarshalYAML(unmarshaler func(interface{}) error) error {
 
 	simpleDefinition := new(string)
-	parameterizedDefinition := make(map[string]DefinitionParameters)
+	parameterizedDefinition := make(map[string]map[string]Any)
 
 	var err error
 
@@ -283,7 +359,32 @@ func (dc *DefinitionChoice) UnmarshalYAML(unmarshaler func(interface{}) error) e
 		// Didn't work? Now unmarshal into a map
 		for choice, params := range parameterizedDefinition {
 			dc.Name = choice
-			dc.Parameters = params
+			dc.Parameters = nil
+			for name, value := range params {
+
+				var ok bool
+				switch name {
+				case "(only)":
+					dc.Only, ok = methodNameList(value)
+				case "(except)":
+					dc.Except, ok = methodNameList(value)
+				default:
+					var parameter string
+					if parameter, ok = parameterValue(value); ok {
+						if dc.Parameters == nil {
+							dc.Parameters = make(DefinitionParameters)
+						}
+						dc.Parameters[name] = parameter
+					}
+				}
+
+				if !ok {
+					if dc.invalid == nil {
+						dc.invalid = make(map[string]Any)
+					}
+					dc.invalid[name] = value
+				}
+			}
 		}
 	}
 
@@ -292,6 +393,87 @@ func (dc *DefinitionChoice) UnmarshalYAML(unmarshaler func(interface{}) error) e
 	return err
 }
 
+// Marshal into a simple string, or a map holding the parameters when there
+// are any
+func (dc DefinitionChoice) MarshalYAML() (interface{}, error) {
+
+	if len(dc.Parameters) == 0 && len(dc.Only) == 0 && len(dc.Except) == 0 {
+		return dc.Name, nil
+	}
+
+	params := make(map[string]interface{})
+	for name, value := range dc.Parameters {
+		params[name] = value
+	}
+	if len(dc.Only) > 0 {
+		params["(only)"] = dc.Only
+	}
+	if len(dc.Except) > 0 {
+		params["(except)"] = dc.Except
+	}
+
+	return map[string]interface{}{dc.Name: params}, nil
+}
+
+// Returns the value of a parameter as a string. Parameters MUST be strings,
+// though YAML considers some of them numbers or booleans. Reports whether
+// the value is one of these.
+func parameterValue(value Any) (string, bool) {
+	switch value.(type) {
+	case nil:
+		return "", true
+	case string, int, int64, uint64, float64, bool:
+		return fmt.Sprint(value), true
+	}
+	return "", false
+}
+
+// Returns the method names listed by an (only) or (except) parameter, in
+// lower case. A single name needn't be in a list. Reports whether the value
+// lists names only.
+func methodNameList(value Any) ([]string, bool) {
+
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+
+	var names []string
+	for _, value := range values {
+		method, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, strings.ToLower(method))
+	}
+
+	return names, true
+}
+
+// Reports whether a trait applied to a resource applies to the method with
+// the given name, according to its (only) and (except) lists
+func (dc *DefinitionChoice) AppliesTo(method string) bool {
+
+	method = strings.ToLower(method)
+
+	if len(dc.Only) > 0 {
+		for _, name := range dc.Only {
+			if name == method {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range dc.Except {
+		if name == method {
+			return false
+		}
+	}
+
+	return true
+}
+
 // A trait is a partial method definition that, like a method, can provide
 // method-level properties such as description, headers, query string
 // parameters, and responses. Methods that use one or more traits inherit
@@ -302,14 +484,12 @@ type Trait struct {
 	// by double angle brackets (double chevrons) enclosing the parameter name;
 	// for example, "<<tokenName>>".
 
-	// TODO: Auto-fill the methodName parameter
-
-	// TODO: In trait definitions, there is one reserved parameter name,
+	// In trait definitions, there is one reserved parameter name,
 	// methodName, in addition to the resourcePath and resourcePathName. The
 	// processing application MUST set the value of the methodName parameter
 	// to the inheriting method's name. The processing application MUST set
 	// the values of the resourcePath and resourcePathName parameters the same
-	// as in resource type definitions.
+	// as in resource type definitions. Resolve sets these.
 
 	// TODO: Parameter values MAY further be transformed by applying one of
 	// the following functions:
@@ -321,7 +501,7 @@ type Trait struct {
 	// supported by this version of RAML is United States English.
 
 	Name string
-	// TODO: Fill this during the post-processing phase
+	// Set from its key in the RAML document when parsing
 
 	// The usage property of a resource type or trait is used to describe how
 	// the resource type or trait should be used
@@ -366,7 +546,7 @@ type Trait struct {
 // doesn't contain Usage, optional fields etc.
 type ResourceTypeMethod struct {
 	Name string
-	// TODO: Fill this during the post-processing phase
+	// Set from its key in the RAML document when parsing
 
 	// Briefly describes what the method does to the resource
 	Description string
@@ -404,21 +584,18 @@ type ResourceTypeMethod struct {
 // a resource type inherit its properties, such as its methods.
 type ResourceType struct {
 
-	// TODO: Auto-fill the resourcePath and resourcePathName parameters
-	// Remove mediaTypeExtension.
-
 	// TODO: Parameters MUST be indicated in resource type and trait definitions
 	// by double angle brackets (double chevrons) enclosing the parameter name;
 	// for example, "<<tokenName>>".
 
-	// TODO: In resource type definitions, there are two reserved parameter
+	// In resource type definitions, there are two reserved parameter
 	// names: resourcePath and resourcePathName. The processing application
 	// MUST set the values of these reserved parameters to the inheriting
 	// resource's path (for example, "/users") and the part of the path
 	// following the rightmost "/" (for example, "users"), respectively.
 	// Processing applications MUST also omit the value of any
 	// mediaTypeExtension found in the resource's URI when setting
-	// resourcePath and resourcePathName.
+	// resourcePath and resourcePathName. Resolve sets these.
 
 	// TODO: Parameter values MAY further be transformed by applying one of
 	// the following functions:
@@ -431,7 +608,7 @@ type ResourceType struct {
 
 	// Name of the resource type
 	Name string
-	// TODO: Fill this during the post-processing phase
+	// Set from its key in the RAML document when parsing
 
 	// The usage property of a resource type or trait is used to describe how
 	// the resource type or trait should be used
@@ -478,6 +655,47 @@ type ResourceType struct {
 	OptionalPatch             *ResourceTypeMethod       `yaml:"patch?"`
 }
 
+// Returns the resource type's method with the given name, or nil if it
+// isn't defined
+func (rt *ResourceType) method(name string) *ResourceTypeMethod {
+	switch name {
+	case "get":
+		return rt.Get
+	case "head":
+		return rt.Head
+	case "post":
+		return rt.Post
+	case "put":
+		return rt.Put
+	case "delete":
+		return rt.Delete
+	case "patch":
+		return rt.Patch
+	}
+	return nil
+}
+
+// Returns the resource type's optional method with the given name, which
+// only applies to resources defining the method themselves, or nil if it
+// isn't defined
+func (rt *ResourceType) optionalMethod(name string) *ResourceTypeMethod {
+	switch name {
+	case "get":
+		return rt.OptionalGet
+	case "head":
+		return rt.OptionalHead
+	case "post":
+		return rt.OptionalPost
+	case "put":
+		return rt.OptionalPut
+	case "delete":
+		return rt.OptionalDelete
+	case "patch":
+		return rt.OptionalPatch
+	}
+	return nil
+}
+
 // A trait-like structure to a security scheme mechanism so as to extend
 // the mechanism, such as specifying response codes, HTTP headers or custom
 // documentation.
@@ -492,7 +710,7 @@ type SecuritySchemeMethod struct {
 // requests, and determine access level and data visibility.
 type SecurityScheme struct {
 	Name string
-	// TODO: Fill this during the post-processing phase
+	// Set from its key in the RAML document when parsing
 
 	// Briefly describes the security scheme
 	Description string
@@ -513,7 +731,7 @@ type SecurityScheme struct {
 	// SHOULD describe the security schemes' required artifacts, such as
 	// headers, URI parameters, and so on.
 	// Including the security schemes' description completes an API's documentation.
-	DescribedBy SecuritySchemeMethod
+	DescribedBy SecuritySchemeMethod `yaml:"describedBy"`
 
 	// The settings attribute MAY be used to provide security schema-specific
 	// information. Depending on the value of the type parameter, its attributes
@@ -531,7 +749,7 @@ type SecurityScheme struct {
 // Methods are operations that are performed on a resource
 type Method struct {
 	Name string
-	// TODO: Fill this during the post-processing phase
+	// Set from its key in the RAML document when parsing
 
 	// Briefly describes what the method does to the resource
 	Description string
@@ -587,23 +805,112 @@ type Method struct {
 	// TODO: Add support for inline traits?
 }
 
+// The caching behaviour of a method, as declared by the Cache-Control and
+// Vary headers of its responses. Only responses declaring those headers
+// appear in the maps.
+type CachePolicy struct {
+
+	// The Cache-Control directives of each response. The directives are
+	// taken from the header's default value, or from its example if it has
+	// no default. A response declaring the header without a value maps to
+	// an empty string.
+	CacheControl map[HTTPCode]string
+
+	// The request headers each response varies on, as listed by the
+	// declared Vary header.
+	Vary map[HTTPCode][]string
+}
+
+// Returns the caching behaviour declared by the method's responses
+func (m *Method) CachePolicy() CachePolicy {
+
+	policy := CachePolicy{
+		CacheControl: make(map[HTTPCode]string),
+		Vary:         make(map[HTTPCode][]string),
+	}
+
+	for code, response := range m.Responses {
+		for name, header := range response.Headers {
+
+			// Header names are case insensitive
+			switch {
+			case strings.EqualFold(string(name), "Cache-Control"):
+				policy.CacheControl[code] = declaredHeaderValue(header)
+			case strings.EqualFold(string(name), "Vary"):
+				var vary []string
+				for _, field := range strings.Split(declaredHeaderValue(header), ",") {
+					if field = strings.TrimSpace(field); field != "" {
+						vary = append(vary, field)
+					}
+				}
+				policy.Vary[code] = vary
+			}
+		}
+	}
+
+	return policy
+}
+
+// The value a header declaration documents: its default, or its example
+func declaredHeaderValue(header Header) string {
+	if value, ok := header.Default.(string); ok && value != "" {
+		return value
+	}
+	return header.Example
+}
+
+// Returns the header the method declares with the given name, ignoring case
+func (m *Method) Header(name string) (Header, bool) {
+	return lookupHeader(m.Headers, name)
+}
+
+// Returns the header the response declares with the given name, ignoring
+// case
+func (r *Response) Header(name string) (Header, bool) {
+	return lookupHeader(r.Headers, name)
+}
+
+// Returns the header the body declares with the given name, ignoring case
+func (b *Body) Header(name string) (Header, bool) {
+	return lookupHeader(b.Headers, name)
+}
+
+// Returns the header with the given name, ignoring case. Parsed definitions
+// have canonical header names, but others might not.
+func lookupHeader(headers map[HTTPHeader]Header, name string) (Header, bool) {
+
+	canonical := HTTPHeader(textproto.CanonicalMIMEHeaderKey(name))
+	if header, ok := headers[canonical]; ok {
+		return header, true
+	}
+
+	for declared, header := range headers {
+		if strings.EqualFold(string(declared), name) {
+			return header, true
+		}
+	}
+
+	return Header{}, false
+}
+
 // A resource is the conceptual mapping to an entity or set of entities.
 type Resource struct {
 
 	// Resources are identified by their relative URI, which MUST begin with
 	// a slash (/).
 	URI string
-	// TODO: Fill this during the post-processing phase
+	// Set from its key in the RAML document when parsing
 
 	// A resource defined as a child property of another resource is called a
 	// nested resource, and its property's key is its URI relative to its
 	// parent resource's URI. If this is not nil, then this resource is a
 	// child resource.
-	Parent *Resource
-	// TODO: Fill this during the post-processing phase
+	// Set when parsing. Top-level resources are held by value, so the
+	// parent of their nested resources is a copy of them.
+	Parent *Resource `yaml:"-" json:"-"`
 
 	// A friendly name to the resource
-	DisplayName string
+	DisplayName string `yaml:"displayName"`
 
 	// Briefly describes the resource
 	Description string
@@ -631,13 +938,13 @@ type Resource struct {
 	// The values matched by URI parameters cannot contain slash (/) characters
 	UriParameters map[string]NamedParameter `yaml:"uriParameters"`
 
-	// TODO: If a URI parameter in a resource's relative URI is not explicitly
+	// If a URI parameter in a resource's relative URI is not explicitly
 	// described in a uriParameters property for that resource, it MUST still
 	// be treated as a URI parameter with defaults as specified in the Named
 	// Parameters section of this specification. Its type is "string", it is
 	// required, and its displayName is its name (i.e. without the surrounding
-	// curly brackets [{] and [}]). In the example below, the top-level
-	// resource has two URI parameters, "folderId" and "fileId
+	// curly brackets [{] and [}]). Parsing declares these implicit URI
+	// parameters in UriParameters.
 
 	// TOOD: A special uriParameter, mediaTypeExtension, is a reserved
 	// parameter. It may be specified explicitly in a uriParameters property
@@ -678,6 +985,212 @@ type Resource struct {
 	Nested map[string]*Resource `yaml:",regexp:/.*"`
 }
 
+// Returns the full URI template of the resource, made of its URI and those
+// of its parents, e.g. /users/{userId}
+func (r *Resource) FullURI() string {
+	if r.Parent == nil {
+		return r.URI
+	}
+	return r.Parent.FullURI() + r.URI
+}
+
+// The names of the HTTP methods a resource may define, in the order of the
+// Resource fields
+var methodNames = []string{"get", "head", "post", "put", "delete", "patch"}
+
+// Returns the status codes of the given responses the filter accepts, in
+// order. A nil filter accepts all of them.
+func sortedCodes(responses map[HTTPCode]Response,
+	filter func(HTTPCode) bool) []HTTPCode {
+
+	var codes []HTTPCode
+	for code := range responses {
+		if filter == nil || filter(code) {
+			codes = append(codes, code)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+	return codes
+}
+
+// Reports whether a status code is that of a successful response, i.e. 2xx
+func successful(code HTTPCode) bool {
+	return code >= 200 && code < 300
+}
+
+// Returns the resource's method with the given name, or nil if it isn't
+// defined
+func (r *Resource) method(name string) *Method {
+	switch name {
+	case "get":
+		return r.Get
+	case "head":
+		return r.Head
+	case "post":
+		return r.Post
+	case "put":
+		return r.Put
+	case "delete":
+		return r.Delete
+	case "patch":
+		return r.Patch
+	}
+	return nil
+}
+
+// Sets the resource's method with the given name
+func (r *Resource) setMethod(name string, method *Method) {
+	switch name {
+	case "get":
+		r.Get = method
+	case "head":
+		r.Head = method
+	case "post":
+		r.Post = method
+	case "put":
+		r.Put = method
+	case "delete":
+		r.Delete = method
+	case "patch":
+		r.Patch = method
+	}
+}
+
+// Returns the traits applied to the resource's method with the given name:
+// those applied by the resource to this method, followed by those applied
+// by the method itself
+func (r *Resource) MethodTraits(name string) []DefinitionChoice {
+
+	var traits []DefinitionChoice
+	for _, trait := range r.Is {
+		if trait.AppliesTo(name) {
+			traits = append(traits, trait)
+		}
+	}
+
+	if method := r.method(strings.ToLower(name)); method != nil {
+		traits = append(traits, method.Is...)
+	}
+
+	return traits
+}
+
+// Calls visit for every resource in the tree, parents before their nested
+// resources, with the full URI template of the resource. Resources are
+// visited in order of their URI templates. Top-level resources are passed
+// as copies, so changes to them are not kept.
+func (api *APIDefinition) walkResources(visit func(path string, resource *Resource)) {
+	api.walkResourceNodes(func(path, nodePath string, resource *Resource) {
+		visit(path, resource)
+	})
+}
+
+// Works like walkResources, keeping the changes made to top-level resources
+// too
+func (api *APIDefinition) updateResources(visit func(path string, resource *Resource)) {
+
+	var uris []string
+	for uri := range api.Resources {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		resource := api.Resources[uri]
+		walkResource(uri, "/resources/"+uri, &resource,
+			func(path, nodePath string, resource *Resource) {
+				visit(path, resource)
+			})
+		api.Resources[uri] = resource
+	}
+}
+
+// Works like walkResources, also passing the path of the resource's node in
+// the RAML document, e.g. /resources//users//{userId}
+func (api *APIDefinition) walkResourceNodes(
+	visit func(path, nodePath string, resource *Resource)) {
+
+	var uris []string
+	for uri := range api.Resources {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		resource := api.Resources[uri]
+		walkResource(uri, "/resources/"+uri, &resource, visit)
+	}
+}
+
+// Calls visit for the resource and then for its nested resources
+func walkResource(path, nodePath string, resource *Resource,
+	visit func(path, nodePath string, resource *Resource)) {
+
+	visit(path, nodePath, resource)
+
+	var uris []string
+	for uri := range resource.Nested {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		walkResource(path+uri, nodePath+"/"+uri, resource.Nested[uri], visit)
+	}
+}
+
+// Calls visit for the request bodies and the response bodies of every method
+// of every resource, in order, with the full URI template of the resource,
+// the method name, the status code of the response or 0 for the request,
+// and the path of the node of the bodies. The bodies must not be changed,
+// as they may be shared with other readers; use updateBodies for that.
+func (api *APIDefinition) walkBodies(visit func(path, method string,
+	code HTTPCode, nodePath string, bodies *Bodies)) {
+	api.visitBodies(visit, false)
+}
+
+// Works like walkBodies, keeping the changes made to the bodies
+func (api *APIDefinition) updateBodies(visit func(path, method string,
+	code HTTPCode, nodePath string, bodies *Bodies)) {
+	api.visitBodies(visit, true)
+}
+
+// Calls visit for every body like walkBodies. Responses are held in maps by
+// value, so visit is given a copy of each, which is only stored back when
+// keeping the changes.
+func (api *APIDefinition) visitBodies(visit func(path, method string,
+	code HTTPCode, nodePath string, bodies *Bodies), keep bool) {
+
+	api.walkResourceNodes(func(path, nodePath string, resource *Resource) {
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil {
+				continue
+			}
+
+			// Request bodies are copied too when only reading them
+			methodPath := nodePath + "/" + name
+			if keep {
+				visit(path, name, 0, methodPath+"/body", &method.Bodies)
+			} else {
+				bodies := method.Bodies
+				visit(path, name, 0, methodPath+"/body", &bodies)
+			}
+
+			for _, code := range sortedCodes(method.Responses, nil) {
+				response := method.Responses[code]
+				visit(path, name, code, fmt.Sprintf(
+					"%s/responses/%d/body", methodPath, code), &response.Bodies)
+				if keep {
+					method.Responses[code] = response
+				}
+			}
+		}
+	})
+}
+
 // TODO: Resource.GetBaseURIParameter --> includeds APIDefinition BURIParams..
 // TODO: Resource.GetAbsoluteURI
 
@@ -702,7 +1215,7 @@ type APIDefinition struct {
 	// base URI parameters are available for replacement:
 	//
 	// version - The content of the version field.
-	BaseUri string
+	BaseUri string `yaml:"baseUri"`
 	// TODO: If a URI template variable in the base URI is not explicitly
 	// described in a baseUriParameters property, and is not specified in a
 	// resource-level baseUriParameters property, it MUST still be treated as
@@ -755,15 +1268,15 @@ type APIDefinition struct {
 	// The value of the schemas property is an array of maps; in each map,
 	// the keys are the schema name, and the values are schema definitions:
 	// []map[SchemaName]SchemaString
+	// Use GetSchema to look up a schema by name.
 	Schemas []map[string]string
-	// TODO: Flatten the arrays of maps here.
 
 	// The securitySchemes property MUST be used to specify an API's security
 	// mechanisms, including the required settings and the authentication
 	// methods that the API supports.
 	// []map[SchemeName]SecurityScheme
+	// Use GetSecurityScheme to look up a security scheme by name.
 	SecuritySchemes []map[string]SecurityScheme `yaml:"securitySchemes"`
-	// TODO: Flatten the arrays of maps here.
 
 	// To apply a securityScheme definition to every method in an API, the
 	// API MAY be defined using the securedBy attribute. This specifies that
@@ -784,8 +1297,8 @@ type APIDefinition struct {
 	// included in the traits declaration, or b) one or more trait definition
 	// maps.
 	// []map[TraitName]Trait
+	// Use GetTrait to look up a trait by name.
 	Traits []map[string]Trait `yaml:"traits"`
-	// TODO: Flatten the arrays of maps here.
 
 	// The resourceTypes and traits properties are declared at the API
 	// definition's root level with the resourceTypes and traits property keys,
@@ -793,8 +1306,8 @@ type APIDefinition struct {
 	// in each map, the keys are resourceType or trait names, and the values
 	// are resourceType or trait definitions, respectively.
 	// []map[ResourceTypeName]ResourceType
+	// Use GetResourceType to look up a resource type by name.
 	ResourceTypes []map[string]ResourceType `yaml:"resourceTypes"`
-	// TODO: Flatten the arrays of maps here.
 
 	// Resources are identified by their relative URI, which MUST begin with a
 	// slash (/). A resource defined as a root-level property is called a
@@ -803,10 +1316,33 @@ type APIDefinition struct {
 	// resource is called a nested resource, and its property's key is its
 	// URI relative to its parent resource's URI.
 	Resources map[string]Resource `yaml:",regexp:/.*"`
+
+	// Problems found while parsing that don't make the RAML document
+	// invalid, e.g. resources without a display name. Reported as errors
+	// instead when parsing with ParserOptions.WarningsAsErrors.
+	Warnings []ParseError `yaml:"-"`
+
+	// Locates the problems found by Validate in the parsed RAML document.
+	// Nil when the definition wasn't parsed, e.g. when loaded from a
+	// snapshot.
+	index *documentIndex
+
+	// Receives the events of resolving the definition, as set in the
+	// options it was parsed with
+	listener ParseListener
 }
 
-// This function receives a path, splits it and traverses the resource
-// tree to find the appropriate resource
-func (r *APIDefinition) GetResource(path string) *Resource {
-	return nil
+// Returns the base URI with {version} replaced by the version of the API,
+// e.g. https://api.example.com/v1 for https://api.example.com/{version}.
+// Other URI parameters are left as they are. Fails if the base URI refers to
+// {version} but no version is declared.
+func (r *APIDefinition) ResolvedBaseUri() (string, error) {
+	if !strings.Contains(r.BaseUri, "{version}") {
+		return r.BaseUri, nil
+	}
+	if r.Version == "" {
+		return "", fmt.Errorf("Base URI %s refers to {version}, but no "+
+			"version is declared", r.BaseUri)
+	}
+	return strings.Replace(r.BaseUri, "{version}", r.Version, -1), nil
 }
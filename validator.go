@@ -29,6 +29,15 @@ package raml
 
 // This file contains all of the RAML schema validator related code.
 
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
 // TODO: Inspirations:
 // 		https://www.npmjs.com/package/raml-validate
 //		https://github.com/go-validator/validator
@@ -36,3 +45,923 @@ package raml
 
 // And of course:
 // 		https://github.com/raml-org/raml-java-parser/tree/master/src/main/java/org/raml/parser/rule
+
+// A pair of resources whose URI templates can match the same request path.
+// Routers resolve such pairs differently from each other, so the API may
+// behave differently depending on the server implementation.
+type RouteConflict struct {
+
+	// The full URI templates of the two resources, in sorted order
+	First  string
+	Second string
+
+	// Why the two resources conflict
+	Reason string
+}
+
+// Analyzes the resource tree and returns every pair of resources that are
+// ambiguous or shadow each other, e.g. /users/{id} and /users/me.
+func (api *APIDefinition) RouteConflicts() []RouteConflict {
+
+	var paths []string
+	api.walkResources(func(path string, resource *Resource) {
+		paths = append(paths, path)
+	})
+	sort.Strings(paths)
+
+	var conflicts []RouteConflict
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if reason, ok := routesConflict(paths[i], paths[j]); ok {
+				conflicts = append(conflicts,
+					RouteConflict{First: paths[i], Second: paths[j],
+						Reason: reason})
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// Matches a URI template variable, e.g. {userId}
+var uriParameterRegexp = regexp.MustCompile(`{[^}]*}`)
+
+// Checks whether two URI templates can match the same request path, and
+// if so, explains why
+func routesConflict(first string, second string) (string, bool) {
+
+	if first == second {
+		return "the resource is declared more than once", true
+	}
+
+	firstSegments := strings.Split(strings.Trim(first, "/"), "/")
+	secondSegments := strings.Split(strings.Trim(second, "/"), "/")
+
+	if len(firstSegments) != len(secondSegments) {
+		return "", false
+	}
+
+	// Do the templates differ only by the names of their parameters?
+	sameShape := true
+
+	// Does a literal segment compete with a parameter?
+	shadowed := false
+
+	for i := range firstSegments {
+
+		firstSegment, secondSegment := firstSegments[i], secondSegments[i]
+		firstShape := uriParameterRegexp.ReplaceAllString(firstSegment, "{}")
+		secondShape := uriParameterRegexp.ReplaceAllString(secondSegment, "{}")
+		firstLiteral := firstShape == firstSegment
+		secondLiteral := secondShape == secondSegment
+
+		switch {
+		case firstShape == secondShape:
+			// Identical, or only the parameter names differ
+		case firstLiteral && secondLiteral:
+			// Two different literals never match the same path
+			return "", false
+		case !firstLiteral && !secondLiteral:
+			// Two different templates only surely overlap when one of them
+			// matches any segment
+			if firstShape != "{}" && secondShape != "{}" {
+				return "", false
+			}
+			sameShape = false
+		default:
+			template, literal := firstSegment, secondSegment
+			if firstLiteral {
+				template, literal = secondSegment, firstSegment
+			}
+			if !segmentRegexp(template).MatchString(literal) {
+				return "", false
+			}
+			sameShape = false
+			shadowed = true
+		}
+	}
+
+	switch {
+	case sameShape:
+		return "the URI templates differ only by parameter names", true
+	case shadowed:
+		return "a literal path segment is also matched by a URI parameter", true
+	default:
+		return "the URI templates overlap", true
+	}
+}
+
+// Returns a regular expression matching the values of a templated path
+// segment, e.g. {fileId}.json
+func segmentRegexp(segment string) *regexp.Regexp {
+
+	literals := uriParameterRegexp.Split(segment, -1)
+	for i := range literals {
+		literals[i] = regexp.QuoteMeta(literals[i])
+	}
+
+	return regexp.MustCompile("^" + strings.Join(literals, "[^/]+") + "$")
+}
+
+// The result of validating an API definition, ready to be published, e.g.
+// by encoding it as JSON
+type ValidationReport struct {
+
+	// The problems found, errors and warnings alike
+	Issues []ParseError `json:"issues"`
+}
+
+// Returns the issues of the report with the given severity
+func (report *ValidationReport) withSeverity(severity Severity) []ParseError {
+	var issues []ParseError
+	for _, issue := range report.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// Returns the issues making the API definition invalid
+func (report *ValidationReport) Errors() []ParseError {
+	return report.withSeverity(SeverityError)
+}
+
+// Returns the issues that don't make the API definition invalid
+func (report *ValidationReport) Warnings() []ParseError {
+	return report.withSeverity(SeverityWarning)
+}
+
+// Reports whether the API definition is free of errors
+func (report *ValidationReport) Valid() bool {
+	return len(report.Errors()) == 0
+}
+
+// Checks the API definition for authoring mistakes the YAML decoding can't
+// catch, such as references to undeclared traits or invalid parameter
+// types, as well as things likely not meant by its author. When the
+// definition was parsed, the issues point at the lines they were found on.
+func (api *APIDefinition) Validate() *ValidationReport {
+
+	report := &ValidationReport{Issues: []ParseError{}}
+	for _, check := range validationChecks {
+		report.Issues = append(report.Issues, check(api)...)
+	}
+
+	if api.index != nil {
+		for i, issue := range report.Issues {
+			report.Issues[i] = api.index.resolve(issue)
+		}
+	}
+
+	return report
+}
+
+// Checks the API definition like APIDefinition.Validate
+func Validate(api *APIDefinition) *ValidationReport {
+	return api.Validate()
+}
+
+// The checks run by Validate, each returning the problems it found
+var validationChecks = []func(api *APIDefinition) []ParseError{
+	checkRootProperties,
+	checkBaseUri,
+	checkNamedParameters,
+	checkReservedParameters,
+	checkReferences,
+	checkStatusCodes,
+	checkTraitMethods,
+	checkResourceTypeMerges,
+	checkConstraints,
+	checkExampleSchemas,
+	checkParameters,
+	checkWarnings,
+	checkDesign,
+	checkCachePolicies,
+	checkRangeRequests,
+	checkSecurity,
+}
+
+// Returns a function appending a problem of the given severity to problems,
+// found by the given rule in a node
+func problemReporter(problems *[]ParseError, severity Severity) func(rule string,
+	nodePath string, format string, args ...interface{}) {
+
+	return func(rule string, nodePath string, format string,
+		args ...interface{}) {
+		*problems = append(*problems, ParseError{NodePath: nodePath,
+			Message: fmt.Sprintf(format, args...), Severity: severity,
+			Rule: rule})
+	}
+}
+
+// Checks that the properties every API definition needs are present
+func checkRootProperties(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	if strings.TrimSpace(api.Title) == "" {
+		report("required-title", "", "title is required")
+	}
+
+	return problems
+}
+
+// The protocols an API can be served over
+var supportedProtocols = map[string]bool{
+	"HTTP":  true,
+	"HTTPS": true,
+}
+
+// Checks that the base URI is an absolute HTTP(S) URI consistent with the
+// declared protocols and version, and that resources don't repeat its path.
+func checkBaseUri(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	checkProtocols := func(nodePath string, protocols []string) {
+		for _, protocol := range protocols {
+			if !supportedProtocols[protocol] {
+				report("unsupported-protocol", nodePath, "protocol %s is "+
+					"not supported, must be HTTP or HTTPS", protocol)
+			}
+		}
+	}
+
+	checkProtocols("/protocols", api.Protocols)
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+		for _, name := range methodNames {
+			if method := resource.method(name); method != nil {
+				checkProtocols(nodePath+"/"+name+"/protocols",
+					method.Protocols)
+			}
+		}
+	})
+
+	if _, ok := api.BaseUriParameters["version"]; ok {
+		report("reserved-base-uri-parameter", "/baseUriParameters/version",
+			"version is reserved and can't be declared as a base URI "+
+				"parameter")
+	}
+
+	var names []string
+	for name := range api.BaseUriParameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name != "version" && !strings.Contains(api.BaseUri, "{"+name+"}") {
+			report("unused-base-uri-parameter", "/baseUriParameters/"+name,
+				"base URI parameter %s is not used in baseUri %s", name,
+				api.BaseUri)
+		}
+	}
+
+	if api.BaseUri == "" {
+		return problems
+	}
+
+	if strings.Contains(api.BaseUri, "{version}") && api.Version == "" {
+		report("undeclared-version", "/baseUri", "baseUri %s uses "+
+			"{version}, but no version is declared", api.BaseUri)
+	}
+
+	// Parameters may appear anywhere, even in the host name
+	baseUri, err := url.Parse(
+		uriParameterRegexp.ReplaceAllString(api.BaseUri, "parameter"))
+	if err != nil {
+		report("invalid-base-uri", "/baseUri", "baseUri %s is not a valid "+
+			"URI (Error: %s)", api.BaseUri, err.Error())
+		return problems
+	}
+
+	if !baseUri.IsAbs() || baseUri.Host == "" {
+		report("relative-base-uri", "/baseUri", "baseUri %s is not an "+
+			"absolute URI", api.BaseUri)
+		return problems
+	}
+
+	scheme := strings.ToUpper(baseUri.Scheme)
+	if !supportedProtocols[scheme] {
+		report("unsupported-scheme", "/baseUri", "baseUri %s has "+
+			"unsupported scheme %s, must be http or https", api.BaseUri,
+			baseUri.Scheme)
+	} else if len(api.Protocols) > 0 {
+		declared := false
+		for _, protocol := range api.Protocols {
+			declared = declared || protocol == scheme
+		}
+		if !declared {
+			report("undeclared-protocol", "/baseUri", "baseUri %s uses %s, "+
+				"which is not one of the declared protocols", api.BaseUri,
+				scheme)
+		}
+	}
+
+	// A resource starting with the last segment of the base URI path most
+	// likely repeats it by mistake, e.g. .../v1 and /v1/users
+	if strings.Trim(baseUri.Path, "/") == "" {
+		return problems
+	}
+	segments := strings.Split(strings.TrimRight(api.BaseUri, "/"), "/")
+	lastSegment := segments[len(segments)-1]
+	if strings.Contains(lastSegment, "{") {
+		return problems
+	}
+
+	var uris []string
+	for uri := range api.Resources {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		if strings.Split(strings.TrimPrefix(uri, "/"), "/")[0] == lastSegment {
+			report("repeated-base-uri-segment", "/resources/"+uri,
+				"resource %s repeats the segment %s of baseUri %s", uri,
+				lastSegment, api.BaseUri)
+		}
+	}
+
+	return problems
+}
+
+// The types a named parameter can have
+var namedParameterTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"date":    true,
+	"boolean": true,
+	"file":    true,
+}
+
+// Calls visit for every named parameter of the API, its resources and their
+// methods, with the path of its node. Form parameters are flagged as such.
+func (api *APIDefinition) walkNamedParameters(
+	visit func(nodePath string, parameter NamedParameter, form bool)) {
+
+	visitAll := func(nodePath string, parameters map[string]NamedParameter,
+		form bool) {
+
+		var names []string
+		for name := range parameters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			visit(nodePath+"/"+name, parameters[name], form)
+		}
+	}
+
+	visitHeaders := func(nodePath string, headers map[HTTPHeader]Header) {
+		parameters := make(map[string]NamedParameter)
+		for name, header := range headers {
+			parameters[string(name)] = NamedParameter(header)
+		}
+		visitAll(nodePath, parameters, false)
+	}
+
+	visitBodies := func(nodePath string, bodies Bodies) {
+
+		visitAll(nodePath+"/formParameters", bodies.DefaultFormParameters,
+			true)
+
+		var mediaTypes []string
+		for mediaType := range bodies.ForMIMEType {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+		sort.Strings(mediaTypes)
+
+		for _, mediaType := range mediaTypes {
+			body := bodies.ForMIMEType[mediaType]
+			visitAll(nodePath+"/"+mediaType+"/formParameters",
+				body.FormParameters, true)
+			visitHeaders(nodePath+"/"+mediaType+"/headers", body.Headers)
+		}
+	}
+
+	visitAll("/baseUriParameters", api.BaseUriParameters, false)
+	visitAll("/uriParameters", api.UriParameters, false)
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		visitAll(nodePath+"/baseUriParameters", resource.BaseUriParameters,
+			false)
+		visitAll(nodePath+"/uriParameters", resource.UriParameters, false)
+
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil {
+				continue
+			}
+
+			methodPath := nodePath + "/" + name
+			visitHeaders(methodPath+"/headers", method.Headers)
+			visitAll(methodPath+"/queryParameters", method.QueryParameters,
+				false)
+			visitBodies(methodPath+"/body", method.Bodies)
+
+			for _, code := range sortedCodes(method.Responses, nil) {
+				response := method.Responses[code]
+				responsePath := fmt.Sprintf("%s/responses/%d", methodPath, code)
+				visitHeaders(responsePath+"/headers", response.Headers)
+				visitBodies(responsePath+"/body", response.Bodies)
+			}
+		}
+	})
+}
+
+// Checks that named parameters have valid types, and only use the
+// attributes their type supports
+func checkNamedParameters(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	api.walkNamedParameters(func(nodePath string, parameter NamedParameter,
+		form bool) {
+
+		parameterType := parameterType(parameter)
+		if !namedParameterTypes[parameterType] {
+			report("named-parameter-type", nodePath, "type %s is not a "+
+				"valid named parameter type", parameterType)
+			return
+		}
+
+		if parameterType == "file" && !form {
+			report("file-parameter", nodePath, "type file is only allowed "+
+				"for form parameters")
+		}
+
+		if parameterType != "string" {
+			if parameter.Pattern != nil {
+				report("string-parameter-attribute", nodePath, "pattern only "+
+					"applies to string parameters")
+			}
+			if parameter.MinLength != nil || parameter.MaxLength != nil {
+				report("string-parameter-attribute", nodePath, "minLength and "+
+					"maxLength only apply to string parameters")
+			}
+		}
+
+		if parameterType != "number" && parameterType != "integer" &&
+			(parameter.Minimum != nil || parameter.Maximum != nil) {
+			report("number-parameter-attribute", nodePath, "minimum and "+
+				"maximum only apply to number and integer parameters")
+		}
+	})
+
+	return problems
+}
+
+// Checks that URI parameters don't redefine the reserved version and
+// mediaTypeExtension parameters. The version is only set through the
+// version property, and mediaTypeExtension can only be narrowed down to
+// some extensions, e.g. with enum: [ .json, .xml ].
+func checkReservedParameters(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	check := func(nodePath string, parameters map[string]NamedParameter) {
+
+		if _, ok := parameters["version"]; ok {
+			report("reserved-uri-parameter", nodePath+"/version",
+				"version is reserved and can't be declared as a URI "+
+					"parameter, its value is set by the version property")
+		}
+
+		extension, ok := parameters["mediaTypeExtension"]
+		if !ok {
+			return
+		}
+
+		extensionPath := nodePath + "/mediaTypeExtension"
+		if parameterType(extension) != "string" {
+			report("reserved-uri-parameter", extensionPath,
+				"mediaTypeExtension is reserved and must be a string, "+
+					"not of type %s", extension.Type)
+		}
+
+		for _, value := range extension.Enum {
+			if text, ok := value.(string); !ok || !strings.HasPrefix(text, ".") {
+				report("reserved-uri-parameter", extensionPath,
+					"mediaTypeExtension is reserved and can only take "+
+						"media type extensions starting with a dot, "+
+						"e.g. .json, not %v", value)
+			}
+		}
+	}
+
+	check("/uriParameters", api.UriParameters)
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+		check(nodePath+"/baseUriParameters", resource.BaseUriParameters)
+		check(nodePath+"/uriParameters", resource.UriParameters)
+	})
+
+	return problems
+}
+
+// Matches a body schema that names a declared schema rather than defining
+// one inline
+var schemaNameRegexp = regexp.MustCompile(`^[\w.-]+$`)
+
+// Checks that the resource types, traits, security schemes and schemas the
+// API refers to are declared
+func checkReferences(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	traits := make(map[string]bool)
+	for _, declared := range api.Traits {
+		for name := range declared {
+			traits[name] = true
+		}
+	}
+
+	resourceTypes := make(map[string]bool)
+	for _, declared := range api.ResourceTypes {
+		for name := range declared {
+			resourceTypes[name] = true
+		}
+	}
+
+	securitySchemes := make(map[string]bool)
+	for _, declared := range api.SecuritySchemes {
+		for name := range declared {
+			securitySchemes[name] = true
+		}
+	}
+
+	schemas := make(map[string]bool)
+	for _, declared := range api.Schemas {
+		for name := range declared {
+			schemas[name] = true
+		}
+	}
+
+	checkTraits := func(nodePath string, choices []DefinitionChoice) {
+		for _, choice := range choices {
+			if !traits[choice.Name] {
+				report("undeclared-trait", nodePath, "trait %s is not "+
+					"declared", choice.Name)
+			}
+		}
+	}
+
+	// Securing with null means the method may also be called unsecured
+	checkSecuredBy := func(nodePath string, choices []DefinitionChoice) {
+		for _, choice := range choices {
+			if choice.Name != "" && choice.Name != "null" &&
+				!securitySchemes[choice.Name] {
+				report("undeclared-security-scheme", nodePath,
+					"security scheme %s is not declared", choice.Name)
+			}
+		}
+	}
+
+	checkSchema := func(nodePath string, schema string) {
+		schema = strings.TrimSpace(schema)
+		if schemaNameRegexp.MatchString(schema) && !schemas[schema] {
+			report("undeclared-schema", nodePath, "schema %s is not declared", schema)
+		}
+	}
+
+	checkBodies := func(nodePath string, bodies Bodies) {
+
+		checkSchema(nodePath+"/schema", bodies.DefaultSchema)
+
+		var mediaTypes []string
+		for mediaType := range bodies.ForMIMEType {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+		sort.Strings(mediaTypes)
+
+		for _, mediaType := range mediaTypes {
+			checkSchema(nodePath+"/"+mediaType+"/schema",
+				bodies.ForMIMEType[mediaType].Schema)
+		}
+	}
+
+	checkSecuredBy("/securedBy", api.SecuredBy)
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		if resource.Type != nil && !resourceTypes[resource.Type.Name] {
+			report("undeclared-resource-type", nodePath+"/type",
+				"resource type %s is not declared", resource.Type.Name)
+		}
+
+		checkTraits(nodePath+"/is", resource.Is)
+		checkSecuredBy(nodePath+"/securedBy", resource.SecuredBy)
+
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil {
+				continue
+			}
+
+			methodPath := nodePath + "/" + name
+			checkTraits(methodPath+"/is", method.Is)
+			checkSecuredBy(methodPath+"/securedBy", method.SecuredBy)
+			checkBodies(methodPath+"/body", method.Bodies)
+
+			for _, code := range sortedCodes(method.Responses, nil) {
+				checkBodies(fmt.Sprintf("%s/responses/%d/body", methodPath,
+					code), method.Responses[code].Bodies)
+			}
+		}
+	})
+
+	return problems
+}
+
+// Checks that the parameters resources declare don't contradict those they
+// inherit from their resource type once merged, e.g. a minLength inherited
+// from the resource type greater than the maxLength the resource declares.
+// Optional methods of resource types are only checked against the methods
+// resources declare, since they don't apply otherwise.
+func checkResourceTypeMerges(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	check := func(nodePath, resourceType string,
+		inherited []map[string]NamedParameter,
+		declared map[string]NamedParameter) {
+
+		var names []string
+		for name := range declared {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			for _, parameters := range inherited {
+				parameter, ok := parameters[name]
+				if !ok {
+					continue
+				}
+				for _, conflict := range mergeConflicts(parameter, declared[name]) {
+					report("resource-type-merge", nodePath+"/"+name,
+						"merging with resource type %s gives %s",
+						resourceType, conflict)
+				}
+			}
+		}
+	}
+
+	headerParameters := func(headers map[HTTPHeader]Header) map[string]NamedParameter {
+		parameters := make(map[string]NamedParameter)
+		for name, header := range headers {
+			parameters[string(name)] = NamedParameter(header)
+		}
+		return parameters
+	}
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		if resource.Type == nil {
+			return
+		}
+
+		name := resource.Type.Name
+		resourceType, ok := api.GetResourceType(name)
+		if !ok {
+			return
+		}
+
+		check(nodePath+"/uriParameters", name,
+			[]map[string]NamedParameter{resourceType.UriParameters,
+				resourceType.OptionalUriParameters},
+			resource.UriParameters)
+		check(nodePath+"/baseUriParameters", name,
+			[]map[string]NamedParameter{resourceType.BaseUriParameters,
+				resourceType.OptionalBaseUriParameters},
+			resource.BaseUriParameters)
+
+		for _, methodName := range methodNames {
+
+			method := resource.method(methodName)
+			if method == nil {
+				continue
+			}
+
+			methodPath := nodePath + "/" + methodName
+			for _, typeMethod := range []*ResourceTypeMethod{
+				resourceType.method(methodName),
+				resourceType.optionalMethod(methodName)} {
+
+				if typeMethod == nil {
+					continue
+				}
+
+				check(methodPath+"/queryParameters", name,
+					[]map[string]NamedParameter{typeMethod.QueryParameters},
+					method.QueryParameters)
+				check(methodPath+"/headers", name,
+					[]map[string]NamedParameter{
+						headerParameters(typeMethod.Headers)},
+					headerParameters(method.Headers))
+			}
+		}
+	})
+
+	return problems
+}
+
+// Returns a description of each contradiction between the bounds of a
+// parameter and those of the parameter overriding it, once merged. Bounds
+// contradicting each other within one of the parameters are left to other
+// checks.
+func mergeConflicts(inherited, declared NamedParameter) []string {
+
+	var conflicts []string
+
+	minLength, maxLength := inherited.MinLength, inherited.MaxLength
+	if declared.MinLength != nil {
+		minLength = declared.MinLength
+	}
+	if declared.MaxLength != nil {
+		maxLength = declared.MaxLength
+	}
+	if minLength != nil && maxLength != nil && *minLength > *maxLength &&
+		(declared.MinLength == nil) != (declared.MaxLength == nil) {
+		conflicts = append(conflicts, fmt.Sprintf("minLength %d greater "+
+			"than maxLength %d", *minLength, *maxLength))
+	}
+
+	minimum, maximum := inherited.Minimum, inherited.Maximum
+	if declared.Minimum != nil {
+		minimum = declared.Minimum
+	}
+	if declared.Maximum != nil {
+		maximum = declared.Maximum
+	}
+	if minimum != nil && maximum != nil &&
+		minimum.Rat().Cmp(maximum.Rat()) > 0 &&
+		(declared.Minimum == nil) != (declared.Maximum == nil) {
+		conflicts = append(conflicts, fmt.Sprintf("minimum %s greater "+
+			"than maximum %s", minimum, maximum))
+	}
+
+	return conflicts
+}
+
+// Checks the (only) and (except) lists of the traits applied to resources,
+// and that traits applied to methods don't have any
+func checkTraitMethods(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	known := make(map[string]bool)
+	for _, name := range methodNames {
+		known[name] = true
+	}
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		for _, choice := range resource.Is {
+
+			if len(choice.Only) > 0 && len(choice.Except) > 0 {
+				report("trait-methods", nodePath+"/is", "trait %s can't "+
+					"be given both (only) and (except)", choice.Name)
+			}
+
+			for _, name := range append(choice.Only, choice.Except...) {
+				if !known[name] {
+					report("trait-methods", nodePath+"/is", "trait %s "+
+						"names unknown method %s", choice.Name, name)
+				}
+			}
+		}
+
+		for _, name := range methodNames {
+			if method := resource.method(name); method != nil {
+				for _, choice := range method.Is {
+					if len(choice.Only) > 0 || len(choice.Except) > 0 {
+						report("trait-methods", nodePath+"/"+name+"/is",
+							"trait %s is applied to a method, so it can't "+
+								"be given (only) or (except)", choice.Name)
+					}
+				}
+			}
+		}
+	})
+
+	return problems
+}
+
+// Checks that responses are declared for valid HTTP status codes
+func checkStatusCodes(api *APIDefinition) []ParseError {
+
+	var problems []ParseError
+	report := problemReporter(&problems, SeverityError)
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil {
+				continue
+			}
+
+			for _, code := range sortedCodes(method.Responses, nil) {
+				if code < 100 || code > 599 {
+					report("status-code", fmt.Sprintf("%s/%s/responses/%d", nodePath, name,
+						code), "%d is not a valid HTTP status code", code)
+				}
+			}
+		}
+	})
+
+	return problems
+}
+
+// The registered top-level media types
+var topLevelMediaTypes = map[string]bool{
+	"application": true,
+	"audio":       true,
+	"example":     true,
+	"font":        true,
+	"image":       true,
+	"message":     true,
+	"model":       true,
+	"multipart":   true,
+	"text":        true,
+	"video":       true,
+}
+
+// Checks the API definition for things that are valid, but likely not what
+// its author meant, and returns them as warnings.
+func checkWarnings(api *APIDefinition) []ParseError {
+
+	var warnings []ParseError
+	report := problemReporter(&warnings, SeverityWarning)
+
+	checkMediaType := func(nodePath string, mediaType string) {
+		parsed, _, err := mime.ParseMediaType(mediaType)
+		if err != nil || !strings.Contains(parsed, "/") ||
+			!topLevelMediaTypes[strings.SplitN(parsed, "/", 2)[0]] {
+			report("non-standard-media-type", nodePath, "media type %s is "+
+				"not a standard media type", mediaType)
+		}
+	}
+
+	checkBodies := func(nodePath string, bodies Bodies) {
+		var mediaTypes []string
+		for mediaType := range bodies.ForMIMEType {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+		sort.Strings(mediaTypes)
+
+		for _, mediaType := range mediaTypes {
+			checkMediaType(nodePath+"/"+mediaType, mediaType)
+		}
+	}
+
+	if api.MediaType != "" {
+		checkMediaType("/mediaType", api.MediaType)
+	}
+
+	api.walkResourceNodes(func(uri, nodePath string, resource *Resource) {
+
+		if resource.DisplayName == "" {
+			report("missing-display-name", nodePath, "resource %s has no "+
+				"displayName", uri)
+		}
+
+		for _, name := range methodNames {
+
+			method := resource.method(name)
+			if method == nil {
+				continue
+			}
+
+			methodPath := nodePath + "/" + name
+			if strings.TrimSpace(method.Description) == "" {
+				report("missing-description", methodPath, "method %s of resource %s has no "+
+					"description", name, uri)
+			}
+
+			checkBodies(methodPath+"/body", method.Bodies)
+
+			for _, code := range sortedCodes(method.Responses, nil) {
+				checkBodies(fmt.Sprintf("%s/responses/%d/body", methodPath,
+					code), method.Responses[code].Bodies)
+			}
+		}
+	})
+
+	return warnings
+}